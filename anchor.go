@@ -0,0 +1,166 @@
+package consistent
+
+import (
+	"strconv"
+	"sync"
+)
+
+// anchor 为 AnchorHash 算法的实现
+// 预先分配 capacity 个桶，使用数组记录每个桶被删除的顺序，内存只与 capacity 有关，
+// 删除一个桶时只有属于该桶的 key 会重新分配，并且重新分配之后仍然完全均衡，
+// 节点只能是 "0" 到 "capacity-1" 的编号，而不是任意的名称
+type anchor struct {
+	// A[b] 为桶 b 被删除时剩余的桶的数量，工作中的桶为 0
+	a []int
+	// 工作中的桶，前 n 个元素有效
+	w []int
+	// l[b] 为桶 b 在 w 中的下标
+	l []int
+	// k[b] 为桶 b 被删除时替代它的桶
+	k []int
+	// 被删除的桶，只能按照相反的顺序重新添加
+	removed []int
+	// 工作中的桶的数量
+	n int
+	// 采用的hash算法
+	hash Hash64
+	sync.RWMutex
+}
+
+// NewAnchor 创建新的 AnchorHash 实例，最多拥有 capacity 个桶，初始没有任何桶
+// 支持 WithHash 和 WithHash64 参数选项，
+// Add 只接受下一个可以添加的编号：最近一次删除的桶，或者从 "0" 开始的下一个从未使用过的桶，
+// 桶的数量达到 capacity 时 Add 返回 false，Delete 可以删除任意一个工作中的桶
+func NewAnchor(capacity int, options ...Option) ConsistentHasher {
+	if capacity < 0 {
+		capacity = 0
+	}
+	c := config(options...)
+	h := &anchor{
+		a:       make([]int, capacity),
+		w:       make([]int, capacity),
+		l:       make([]int, capacity),
+		k:       make([]int, capacity),
+		removed: make([]int, 0, capacity),
+		hash:    c.hash,
+	}
+	for b := 0; b < capacity; b++ {
+		h.w[b], h.l[b], h.k[b] = b, b, b
+	}
+	// 所有的桶都视为按照编号从大到小依次被删除
+	for b := capacity - 1; b >= 0; b-- {
+		h.removed = append(h.removed, b)
+		h.a[b] = b
+	}
+	return h
+}
+
+// bucket 解析桶的编号，编号不合法时返回 -1
+func (h *anchor) bucket(slot string) int {
+	b, err := strconv.Atoi(slot)
+	if err != nil || b < 0 || b >= len(h.a) || slot != strconv.Itoa(b) {
+		return -1
+	}
+	return b
+}
+
+// working 判断桶是否在工作中
+func (h *anchor) working(b int) bool {
+	return h.l[b] < h.n && h.w[h.l[b]] == b
+}
+
+// Add 添加下一个可以添加的桶，slot 必须为最近一次删除的桶的编号
+func (h *anchor) Add(slot string) bool {
+	h.Lock()
+	defer h.Unlock()
+	if h.n == len(h.a) || slot != strconv.Itoa(h.removed[len(h.removed)-1]) {
+		return false
+	}
+	b := h.removed[len(h.removed)-1]
+	h.removed = h.removed[:len(h.removed)-1]
+	h.a[b] = 0
+	h.l[h.w[h.n]] = h.n
+	h.w[h.l[b]] = b
+	h.k[b] = b
+	h.n++
+	return true
+}
+
+// Delete 删除一个工作中的桶，属于该桶的 key 会均匀地分配到剩余的桶中
+func (h *anchor) Delete(slot string) {
+	h.Lock()
+	defer h.Unlock()
+	b := h.bucket(slot)
+	if b < 0 || !h.working(b) {
+		return
+	}
+	h.removed = append(h.removed, b)
+	h.n--
+	h.a[b] = h.n
+	// 使用最后一个工作中的桶填补 b 在 w 中的位置
+	h.w[h.l[b]] = h.w[h.n]
+	h.l[h.w[h.n]] = h.l[b]
+	h.k[b] = h.w[h.n]
+}
+
+// get 获取 key 所在的桶的编号，必须持有锁并且至少有一个工作中的桶
+func (h *anchor) get(key string) int {
+	b := int(h.hash(key) % uint64(len(h.a)))
+	for h.a[b] > 0 {
+		// 在桶 b 被删除时剩余的桶中重新选择
+		next := int(h.hash(key+"#"+strconv.Itoa(b)) % uint64(h.a[b]))
+		for h.a[next] >= h.a[b] {
+			next = h.k[next]
+		}
+		b = next
+	}
+	return b
+}
+
+// Get 获取 key 所在的桶的编号，如果没有任何桶，返回空字符串
+func (h *anchor) Get(key string) string {
+	h.RLock()
+	defer h.RUnlock()
+	if h.n == 0 {
+		return ""
+	}
+	return strconv.Itoa(h.get(key))
+}
+
+// GetN 获取 key 所在的桶以及工作中的桶里之后的 n-1 个桶的编号
+func (h *anchor) GetN(key string, n int) []string {
+	h.RLock()
+	defer h.RUnlock()
+	if n <= 0 || h.n == 0 {
+		return nil
+	}
+	if n > h.n {
+		n = h.n
+	}
+	start := h.l[h.get(key)]
+	res := make([]string, n)
+	for i := range res {
+		res[i] = strconv.Itoa(h.w[(start+i)%h.n])
+	}
+	return res
+}
+
+// Contains 判断桶是否在工作中
+func (h *anchor) Contains(slot string) bool {
+	h.RLock()
+	defer h.RUnlock()
+	b := h.bucket(slot)
+	return b >= 0 && h.working(b)
+}
+
+// Len 获取工作中的桶的数量，不超过 capacity
+func (h *anchor) Len() int {
+	h.RLock()
+	defer h.RUnlock()
+	return h.n
+}
+
+// Close 没有需要释放的资源，总是返回 nil
+func (h *anchor) Close() error {
+	return nil
+}
@@ -0,0 +1,79 @@
+package consistent
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestAnchor(t *testing.T) {
+	h := NewAnchor(16)
+	if server := h.Get("key"); server != "" {
+		t.Fatalf("Get on empty anchor = %q, want empty", server)
+	}
+	if h.Add("1") {
+		t.Fatal("Add(1) before Add(0) returned true")
+	}
+	for b := 0; b < 10; b++ {
+		if !h.Add(strconv.Itoa(b)) {
+			t.Fatalf("Add(%d) returned false", b)
+		}
+	}
+
+	const samples = 10000
+	before := make(map[string]string, samples)
+	statistic := make(map[string]int)
+	for i := 0; i < samples; i++ {
+		key := sampleKey(i)
+		before[key] = h.Get(key)
+		statistic[before[key]]++
+	}
+	t.Log(statistic)
+	if len(statistic) != 10 {
+		t.Fatalf("keys are assigned to %d buckets, want 10", len(statistic))
+	}
+
+	// 删除桶之后只有属于该桶的 key 会发生迁移
+	h.Delete("3")
+	h.Delete("7")
+	if h.Contains("3") || h.Contains("7") || !h.Contains("0") {
+		t.Fatal("Contains does not match the working buckets")
+	}
+	for key, bucket := range before {
+		got := h.Get(key)
+		if got == "3" || got == "7" {
+			t.Fatalf("Get(%q) = %s after Delete", key, got)
+		}
+		if bucket != "3" && bucket != "7" && got != bucket {
+			t.Fatalf("Get(%q) moved from surviving bucket %s to %s", key, bucket, got)
+		}
+	}
+
+	// 按照相反的顺序重新添加之后恢复原来的分配
+	if h.Add("3") || !h.Add("7") || !h.Add("3") {
+		t.Fatal("re-adding removed buckets out of order")
+	}
+	for key, bucket := range before {
+		if got := h.Get(key); got != bucket {
+			t.Fatalf("Get(%q) = %s after re-adding, want %s", key, got, bucket)
+		}
+		nodes := h.GetN(key, 3)
+		if len(nodes) != 3 || nodes[0] != bucket || nodes[0] == nodes[1] || nodes[1] == nodes[2] {
+			t.Fatalf("GetN(%q, 3) = %v, Get = %s", key, nodes, bucket)
+		}
+	}
+}
+
+func TestAnchorCapacity(t *testing.T) {
+	h := NewAnchor(2)
+	if !h.Add("0") || !h.Add("1") || h.Add("2") {
+		t.Fatal("Add does not respect the capacity")
+	}
+	h.Delete("0")
+	h.Delete("1")
+	if server := h.Get("key"); server != "" {
+		t.Fatalf("Get after deleting all buckets = %q, want empty", server)
+	}
+	if !h.Add("1") || h.Get("key") != "1" {
+		t.Fatal("Get after re-adding bucket 1 did not return it")
+	}
+}
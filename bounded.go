@@ -0,0 +1,145 @@
+package consistent
+
+import (
+	"math"
+	"time"
+)
+
+// WithBoundedLoad 开启负载上限 (consistent hashing with bounded loads)
+// 通过 GetBounded 分配 key 时，每个节点上的 key 的数量不会超过 factor * 平均负载，
+// 超过上限的节点会被跳过，key 顺时针分配到下一个没有达到上限的节点，factor 小于 1 时按 1 处理
+func WithBoundedLoad(factor float64) Option {
+	return func(c *consistent) {
+		if factor < 1 {
+			factor = 1
+		}
+		c.loadFactor = factor
+	}
+}
+
+// WithBoundedLoadTTL 自动释放超过 d 没有再次通过 GetBounded 获取的 key，只在开启负载上限时生效
+// 会启动一个后台 goroutine 定期扫描，不再使用实例时必须调用 Close
+func WithBoundedLoadTTL(d time.Duration) Option {
+	return func(c *consistent) {
+		c.ttl = d
+	}
+}
+
+// GetBounded 在负载上限的约束下获取 key 所属的节点，并记录该 key 的分配情况
+// 已经分配过的 key 直接返回之前分配的节点，不再使用时需要调用 Release 释放，
+// 没有开启负载上限时等同于 Get
+func (c *consistent) GetBounded(name string) string {
+	if c.loadFactor == 0 {
+		return c.Get(name)
+	}
+	c.gets.Add(1)
+	c.Lock()
+	defer c.Unlock()
+	c.settle()
+	if server, ok := c.assigned[name]; ok {
+		c.touch(name)
+		return server
+	}
+	if c.circle.Len() == 0 {
+		return ""
+	}
+
+	limit := c.loadLimit()
+	start := c.circle.search(c.hash(name))
+	for j := 0; j < c.circle.Len(); j++ {
+		server := c.servers[c.circle[(start+j)%c.circle.Len()]]
+		if c.loads[server] < limit {
+			c.loads[server]++
+			c.assigned[name] = server
+			c.touch(name)
+			return server
+		}
+	}
+	return ""
+}
+
+// Release 释放通过 GetBounded 分配的 key，对应节点的负载减少 1
+func (c *consistent) Release(name string) {
+	c.Lock()
+	defer c.Unlock()
+	server, ok := c.assigned[name]
+	if !ok {
+		return
+	}
+	delete(c.assigned, name)
+	delete(c.touched, name)
+	c.loads[server]--
+}
+
+// Load 获取节点上通过 GetBounded 分配的 key 的数量
+func (c *consistent) Load(node string) int {
+	c.RLock()
+	defer c.RUnlock()
+	return c.loads[node]
+}
+
+// loadLimit 计算再分配一个 key 时每个节点的负载上限 ceil(factor * (total + 1) / nodes)
+func (c *consistent) loadLimit() int {
+	total := len(c.assigned) + 1
+	return int(math.Ceil(c.loadFactor * float64(total) / float64(len(c.nodes))))
+}
+
+// releaseNode 释放分配到节点上的所有 key，这些 key 下次通过 GetBounded 获取时重新分配
+func (c *consistent) releaseNode(node string) {
+	if c.loads[node] == 0 {
+		delete(c.loads, node)
+		return
+	}
+	for name, server := range c.assigned {
+		if server == node {
+			delete(c.assigned, name)
+			delete(c.touched, name)
+		}
+	}
+	delete(c.loads, node)
+}
+
+// touch 记录 key 最近一次通过 GetBounded 获取的时间，必须持有锁
+func (c *consistent) touch(name string) {
+	if c.touched != nil {
+		c.touched[name] = time.Now()
+	}
+}
+
+// startReaper 启动定期释放过期 key 的 goroutine，通过 Close 停止
+func (c *consistent) startReaper() {
+	c.touched = make(map[string]time.Time)
+	c.done = make(chan struct{})
+	c.stopped = make(chan struct{})
+	interval := c.ttl / 2
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	go func() {
+		defer close(c.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.done:
+				return
+			case now := <-ticker.C:
+				c.expire(now)
+			}
+		}
+	}()
+}
+
+// expire 释放在 now 之前已经超过 ttl 没有被获取的 key
+func (c *consistent) expire(now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	for name, touched := range c.touched {
+		if now.Sub(touched) < c.ttl {
+			continue
+		}
+		c.loads[c.assigned[name]]--
+		delete(c.assigned, name)
+		delete(c.touched, name)
+	}
+}
@@ -0,0 +1,119 @@
+package consistent
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBoundedLoad(t *testing.T) {
+	const factor = 1.25
+	c := New(WithBoundedLoad(factor)).(*consistent)
+	for i := 0; i < 10; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+
+	// 相差不多的 key 在默认的哈希函数下会集中到少数节点上
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("hot-%d", i)
+		c.GetBounded(keys[i])
+	}
+
+	limit := int(math.Ceil(factor * float64(len(keys)) / 10))
+	total := 0
+	for _, node := range c.Members() {
+		if load := c.Load(node); load > limit {
+			t.Fatalf("node %s has load %d, exceeds limit %d", node, load, limit)
+		}
+		total += c.Load(node)
+	}
+	if total != len(keys) {
+		t.Fatalf("total load = %d, want %d", total, len(keys))
+	}
+
+	// 已经分配的 key 不会重复计算
+	server := c.GetBounded(keys[0])
+	if c.GetBounded(keys[0]) != server {
+		t.Fatal("GetBounded returned a different node for an assigned key")
+	}
+	load := c.Load(server)
+	c.Release(keys[0])
+	if c.Load(server) != load-1 {
+		t.Fatalf("load after Release = %d, want %d", c.Load(server), load-1)
+	}
+}
+
+func TestBoundedLoadDelete(t *testing.T) {
+	c := New(WithBoundedLoad(1.5)).(*consistent)
+	c.Add("192.168.0.1")
+	c.Add("192.168.0.2")
+	for i := 0; i < 100; i++ {
+		c.GetBounded(fmt.Sprintf("key-%d", i))
+	}
+	c.Delete("192.168.0.1")
+	// 分配到被删除节点上的 key 被释放
+	if load := c.Load("192.168.0.2"); len(c.assigned) != load {
+		t.Fatalf("assigned keys = %d, want %d", len(c.assigned), load)
+	}
+	for i := 0; i < 100; i++ {
+		if server := c.GetBounded(fmt.Sprintf("key-%d", i)); server != "192.168.0.2" {
+			t.Fatalf("GetBounded after Delete = %q, want 192.168.0.2", server)
+		}
+	}
+}
+
+func TestBoundedLoadTTL(t *testing.T) {
+	const ttl = 20 * time.Millisecond
+	c := New(WithBoundedLoad(1.25), WithBoundedLoadTTL(ttl)).(*consistent)
+	defer c.Close()
+	c.AddBatch("192.168.0.1", "192.168.0.2", "192.168.0.3")
+	for i := 0; i < 100; i++ {
+		c.GetBounded(sampleKey(i))
+	}
+	total := func() int {
+		n := 0
+		for _, node := range c.Members() {
+			n += c.Load(node)
+		}
+		return n
+	}
+	if n := total(); n != 100 {
+		t.Fatalf("total load = %d, want 100", n)
+	}
+
+	// 持续获取的 key 不会过期，其他的 key 在 ttl 之后被释放
+	deadline := time.Now().Add(time.Second)
+	for total() > 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("total load = %d after %v, want 1", total(), time.Second)
+		}
+		c.GetBounded(sampleKey(0))
+		time.Sleep(ttl / 4)
+	}
+	c.RLock()
+	_, ok := c.assigned[sampleKey(0)]
+	c.RUnlock()
+	if !ok {
+		t.Fatal("key refreshed by GetBounded expired")
+	}
+}
+
+func TestBoundedLoadTTLClose(t *testing.T) {
+	c := New(WithBoundedLoad(1.25), WithBoundedLoadTTL(time.Millisecond)).(*consistent)
+	c.Add("192.168.0.1")
+	c.Close()
+	select {
+	case <-c.stopped:
+	default:
+		t.Fatal("reaper goroutine is still running after Close")
+	}
+	c.Close()
+	// goroutine 退出之后分配的 key 不再过期
+	c.GetBounded("key")
+	time.Sleep(10 * time.Millisecond)
+	if load := c.Load("192.168.0.1"); load != 1 {
+		t.Fatalf("load after Close = %d, want 1", load)
+	}
+}
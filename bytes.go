@@ -0,0 +1,32 @@
+package consistent
+
+import "unsafe"
+
+// bytesToString 将 []byte 转换成 string，不会复制数据
+// 返回的字符串与 b 共享内存，只能在 b 不被修改的期间内使用，不能被保存下来
+func bytesToString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// GetBytes 与 Get 一致，获取 []byte 类型的 key 所属的节点，不需要先转换成 string
+// key 直接交给哈希函数计算，不会产生额外的内存分配，哈希函数不能保存传入的字符串
+func (c *consistent) GetBytes(key []byte) string {
+	c.gets.Add(1)
+	r := c.load()
+	// 使用 []byte 作为 map 的 key 查找时编译器不会进行复制
+	if server, ok := r.pins[string(key)]; ok {
+		return server
+	}
+	server, _ := r.get(c.hash(bytesToString(key)))
+	return server
+}
+
+// AddBytes 与 Add 一致，节点的名称会被复制一份保存下来
+func (c *consistent) AddBytes(slot []byte) bool {
+	return c.Add(string(slot))
+}
+
+// DeleteBytes 与 Delete 一致
+func (c *consistent) DeleteBytes(slot []byte) {
+	c.Delete(string(slot))
+}
@@ -0,0 +1,55 @@
+package consistent
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGetBytes(t *testing.T) {
+	c := New().(*consistent)
+	if server := c.GetBytes([]byte("key")); server != "" {
+		t.Fatalf("GetBytes on empty ring = %q, want empty", server)
+	}
+	slot := []byte("192.168.0.1")
+	if !c.AddBytes(slot) {
+		t.Fatal("AddBytes returned false")
+	}
+	// 修改传入的 []byte 不会影响已经添加的节点
+	slot[0] = '0'
+	c.AddBytes([]byte("192.168.0.2"))
+	if !c.Contains("192.168.0.1") {
+		t.Fatalf("Members() = %v", c.Members())
+	}
+	for i := 0; i < 1000; i++ {
+		key := sampleKey(i)
+		if server := c.GetBytes([]byte(key)); server != c.Get(key) {
+			t.Fatalf("GetBytes(%q) = %s, want %s", key, server, c.Get(key))
+		}
+	}
+	c.DeleteBytes([]byte("192.168.0.1"))
+	if c.Contains("192.168.0.1") {
+		t.Fatal("Contains returned true after DeleteBytes")
+	}
+}
+
+func BenchmarkGetBytes(b *testing.B) {
+	c := New().(*consistent)
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("nodes-%d", i))
+	}
+	key := []byte("/hello.txt")
+
+	b.Run("Get", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c.Get(string(key))
+		}
+	})
+
+	b.Run("GetBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c.GetBytes(key)
+		}
+	})
+}
@@ -3,36 +3,93 @@
 package consistent
 
 import (
+	"context"
+	"errors"
 	"hash/fnv"
+	"math"
 	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// ErrInvalidReplicas 副本数量不为正数
+var ErrInvalidReplicas = errors.New("consistent: replicas must be positive")
+
 // Hash 将对应的key转换成索引
 type Hash func(string) uint32
 
+// Hash64 将对应的key转换成 64 位的索引
+// 相比于 Hash，节点分布在更大的空间中，副本之间更不容易发生冲突
+type Hash64 func(string) uint64
+
 // 默认的hash函数
 // 测试的发现 fnv hash 函数对于 key 相差不多的
-// 映射出来的 uint32 值十分相近
+// 映射出来的 uint32 值十分相近，所以再经过一次混合，
+// 使得 key-1, key-2, key-3 这样的 key 也能分散在整个圆环上
 func hash(name string) uint32 {
+	return fmix32(fnv32(name))
+}
+
+// fnv32 为 FNV-1 32 位哈希函数
+func fnv32(name string) uint32 {
 	f := fnv.New32()
 	f.Write([]byte(name))
 	return f.Sum32()
 }
 
+// fmix32 为 murmur3 的最终混合函数，输入的任意一位发生变化，输出的每一位都有一半的概率发生变化
+func fmix32(h uint32) uint32 {
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
 // ConsistentHasher 为一致性哈希抽象接口
 type ConsistentHasher interface {
-	// 添加节点
-	Add(slot string)
+	// 添加节点，返回节点是否为新添加的
+	Add(slot string) bool
 	// 删除节点
 	Delete(slot string)
 	// 数据对应的节点
 	Get(key string) string
+	// 数据对应的 n 个不同的物理节点
+	GetN(key string, n int) []string
+	// 节点是否存在
+	Contains(slot string) bool
+	// 物理节点的数量
+	Len() int
+	// 释放后台的 goroutine 以及事件通道等资源，可以重复调用
+	Close() error
+}
+
+// VNodeFormatter 生成节点第 replica 个副本对应的字符串，该字符串经过哈希之后得到副本在圆环上的位置
+// 不同的 (node, replica) 应该生成不同的字符串，否则副本之间必然发生冲突
+type VNodeFormatter func(node string, replica int) string
+
+// 默认的副本格式 node#replica
+// 使用分隔符避免 "1key" 的第 1 个副本和 "key" 的第 11 个副本生成相同的字符串
+func vnodeFormat(node string, replica int) string {
+	return node + "#" + strconv.Itoa(replica)
+}
+
+// vnodeKey 生成默认格式下节点第 replica 个副本第 probe 次冲突探测的字符串，没有冲突时 probe 为 0，与 vnodeFormat 一致
+// 探测的次数以 node#replica/probe 的形式追加，最后一个 '#' 之后只有数字和 '/'，从右向左就可以唯一地解析出
+// (node, replica, probe)，所以不同的组合不会生成相同的字符串，例如 "a" 的第 1 次探测不会与节点 "a#1" 的副本相同
+func vnodeKey(node string, replica, probe int) string {
+	if probe == 0 {
+		return vnodeFormat(node, replica)
+	}
+	return vnodeFormat(node, replica) + "/" + strconv.Itoa(probe)
 }
 
 // 用来保存圆环上的节点
-type uints []uint32
+// 32 位的哈希值同样保存为 uint64，这样 32 位和 64 位的圆环可以共用同一套逻辑
+type uints []uint64
 
 // 实现 sort.Interface 接口
 func (u uints) Len() int {
@@ -47,127 +104,1522 @@ func (u uints) Swap(i, j int) {
 	u[i], u[j] = u[j], u[i]
 }
 
+// search 返回 key 在hash圆环上顺时针遇到的第一个节点的下标
+func (u uints) search(key uint64) int {
+	i := u.lowerBound(key)
+	if i >= u.Len() {
+		i = 0
+	}
+	return i
+}
+
+// lowerBound 返回第一个大于等于 key 的元素的下标，不存在时返回 len(u)
+// 与 sort.Search(len(u), func(i int) bool { return u[i] >= key }) 的结果完全一致，
+// 手写二分查找避免闭包的开销，保证 Get 不会分配内存
+func (u uints) lowerBound(key uint64) int {
+	lo, hi := 0, len(u)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if u[mid] < key {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// ring 为圆环的只读快照，发布之后不会再被修改，读取时不需要加锁
+type ring struct {
+	// 保存所有的索引，也就是在hash圆环上的节点
+	circle uints
+	// 节点所对应的server
+	servers map[uint64]string
+	// 物理节点的数量
+	nodes int
+	// 固定分配的 key 以及所在的节点，只包含节点仍然在圆环上的 key
+	pins map[string]string
+	// 被 MarkDown 标记为不可用的节点，只包含仍然在圆环上的节点
+	down map[string]bool
+}
+
+// 没有任何节点的圆环
+var emptyRing = &ring{servers: map[uint64]string{}}
+
+// lookup 获取 key 所属的节点，固定分配的 key 直接返回固定的节点
+func (r *ring) lookup(name string, hash Hash64) (string, bool) {
+	if server, ok := r.pins[name]; ok {
+		return server, true
+	}
+	return r.get(hash(name))
+}
+
+// get 获取哈希值顺时针遇到的第一个节点
+func (r *ring) get(key uint64) (string, bool) {
+	if r.circle.Len() == 0 {
+		return "", false
+	}
+	return r.servers[r.circle[r.circle.search(key)]], true
+}
+
 // Option 为参数选项，用来设置内部参数
 type Option func(c *consistent)
 
-
 // WithReplicas 自定义副本数量
+// 副本数量为 1 并且没有自定义副本格式时，节点在圆环上的位置直接为节点名称的哈希值，
+// 等价于没有虚拟节点的经典一致性哈希，便于手动推算每个 key 所属的节点，
+// 此时不使用 vnodeKey 的格式，名称形如 "node#1" 的节点可能与其他节点的副本使用相同的字符串
 func WithReplicas(count int) Option {
 	return func(c *consistent) {
 		c.replicas = count
 	}
 }
 
+// WithReplices 自定义副本数量
+//
+// Deprecated: 拼写错误，请使用 WithReplicas，两者行为完全一致
+func WithReplices(count int) Option {
+	return WithReplicas(count)
+}
+
+// WithWeights 批量添加带权重的节点
+// 权重为 w 的节点在圆环上拥有 w * replicas 个副本
+func WithWeights(weights map[string]int) Option {
+	return func(c *consistent) {
+		c.weights = weights
+	}
+}
+
+// WithReplicasPerNode 为指定的节点单独设置副本数量，未列出的节点以及副本数量不为正数的节点使用全局默认的副本数量
+// 对 WithInitialNodes, Add, AddBatch 以及 AddReturningPositions 添加的节点生效，
+// 与 AddWithReplicas 一致，副本数量保存在节点中，之后修改全局的副本数量不会影响这些节点
+func WithReplicasPerNode(replicas map[string]int) Option {
+	return func(c *consistent) {
+		c.perNode = replicas
+	}
+}
+
+// WithFloatWeights 批量添加带小数权重的节点，与 AddWeightFloat 一致，
+// 权重不为正数的节点会被跳过，已经通过 WithWeights 添加的节点同样会被跳过，只对 New 创建的圆环生效
+func WithFloatWeights(weights map[string]float64) Option {
+	return func(c *consistent) {
+		c.floatWeights = weights
+	}
+}
+
+// WithInitialNodes 批量添加权重为 1 的节点
+// 节点在所有的参数选项生效之后才添加到圆环中，所以与 WithReplicas, WithHash 等选项的顺序无关，
+// 所有节点添加完成之后只进行一次排序，已经通过 WithWeights 添加的节点会被跳过
+func WithInitialNodes(slots ...string) Option {
+	return func(c *consistent) {
+		c.initial = slots
+	}
+}
+
+// WithLazySort 添加节点时不排序也不发布快照，推迟到之后第一次读取时一次完成，适合先添加大量节点再读取的场景
+// 添加节点之后的第一次读取需要加写锁等待排序完成
+func WithLazySort() Option {
+	return func(c *consistent) {
+		c.lazy = true
+	}
+}
+
+// WithVNodeFormatter 自定义副本对应的字符串格式，为 nil 时使用默认的格式
+func WithVNodeFormatter(formatter VNodeFormatter) Option {
+	return func(c *consistent) {
+		c.format = formatter
+	}
+}
+
+// WithOnAdd 设置节点添加成功之后的回调函数
+// 回调函数在锁外执行，可以在回调中继续操作圆环，重复添加已经存在的节点不会触发回调
+func WithOnAdd(fn func(slot string)) Option {
+	return func(c *consistent) {
+		c.onAdd = fn
+	}
+}
+
+// WithOnRemove 设置节点删除成功之后的回调函数
+// 回调函数在锁外执行，删除不存在的节点不会触发回调，Reset 会对每一个节点触发回调
+func WithOnRemove(fn func(slot string)) Option {
+	return func(c *consistent) {
+		c.onRemove = fn
+	}
+}
 
 // WithHash 自定义哈希函数
 func WithHash(hash Hash) Option {
+	return func(c *consistent) {
+		c.hash = func(name string) uint64 {
+			return uint64(hash(name))
+		}
+		c.maxPos = math.MaxUint32
+	}
+}
+
+// WithHash64 自定义 64 位哈希函数，节点将分布在 64 位的圆环上
+func WithHash64(hash Hash64) Option {
 	return func(c *consistent) {
 		c.hash = hash
+		c.maxPos = math.MaxUint64
+	}
+}
+
+// WithNamespace 在节点名称和 key 哈希之前加上命名空间 ns，使节点相同的圆环得到相互独立的布局
+// 命名空间不会被保存，Load 时需要传入相同的命名空间
+func WithNamespace(ns string) Option {
+	return func(c *consistent) {
+		c.namespace = ns
+	}
+}
+
+// WithHashFactory 使用 factory 为每个实例创建独立的 32 位哈希函数，例如每个圆环使用不同的种子
+// factory 在创建实例时 (所有的参数选项生效之后) 只调用一次，覆盖其他的哈希选项，
+// 通过 WithShards 划分的子圆环以及 Clone 得到的实例与原来的实例共用同一个哈希函数
+func WithHashFactory(factory func() Hash) Option {
+	return func(c *consistent) {
+		c.hashFactory = factory
+	}
+}
+
+// WithHash32 自定义 32 位哈希函数，与 WithHash 一致，节点将分布在 32 位的圆环上
+func WithHash32(hash Hash) Option {
+	return WithHash(hash)
+}
+
+// HashFunc 为 32 位或者 64 位的哈希函数
+type HashFunc interface {
+	Hash | Hash64 | func(string) uint32 | func(string) uint64
+}
+
+// WithHashFunc 根据哈希函数的返回值类型自动选择圆环的位数
+// 返回 uint64 的哈希函数使用 64 位的圆环，否则使用 32 位的圆环，之后的接口与位数无关；
+// 一个圆环只能有一种位数，所有的节点和 key 都使用同一个哈希函数，
+// 同时使用多个哈希选项时只有最后一个生效，不能混用 32 位和 64 位的哈希函数
+func WithHashFunc[H HashFunc](hash H) Option {
+	switch h := any(hash).(type) {
+	case Hash64:
+		return WithHash64(h)
+	case func(string) uint64:
+		return WithHash64(h)
+	case Hash:
+		return WithHash32(h)
+	default:
+		return WithHash32(h.(func(string) uint32))
+	}
+}
+
+// member 记录一个物理节点的信息
+type member struct {
+	// 节点的权重，副本数量为 weight * replicas
+	weight int
+	// 节点单独指定的副本数量，为 0 时使用 weight * replicas
+	replicas int
+	// 计算副本位置时使用的标识，为空时使用节点名称，Rename 之后为节点原来的名称
+	id string
+	// 节点的副本在圆环上的位置，按照副本的编号排列
+	positions uints
+	// positions 中每个位置对应的副本编号，只在有副本因为冲突被丢弃时记录，为 nil 时 positions[k] 为第 k 个副本
+	indices []int
+	// 是否有副本因为其他节点占用了初始位置而重新探测或者被丢弃，删除节点之后需要重新计算位置
+	displaced bool
+}
+
+// replica 获取 positions[k] 所对应的副本编号
+func (m *member) replica(k int) int {
+	if m.indices == nil {
+		return k
 	}
+	return m.indices[k]
+}
+
+// key 获取计算副本位置时使用的标识
+func (m *member) key(node string) string {
+	if m.id != "" {
+		return m.id
+	}
+	return node
+}
+
+// count 计算节点的副本数量，replicas 为全局默认的副本数量
+func (m *member) count(replicas int) int {
+	if m.replicas > 0 {
+		return m.replicas
+	}
+	return m.weight * replicas
 }
 
 type consistent struct {
 	// 副本数量
 	replicas int
 	// 所有的server 节点
-	nodes map[string]struct{}
-	// 节点所对应的server
-	servers map[uint32]string
-	// 保存所有的索引，也就是在hash圆环上的节点
+	nodes map[string]*member
+	// 初始化时添加的带权重的节点
+	weights map[string]int
+	// 初始化时添加的带小数权重的节点
+	floatWeights map[string]float64
+	// 节点所对应的server，只能在锁内访问
+	servers map[uint64]string
+	// 保存所有的索引，也就是在hash圆环上的节点，只能在锁内访问
 	circle uints
 	// 采用的hash算法
 	// hash 方法可能直接决定节点的分布情况
-	hash Hash
+	// 32 位的哈希函数会被转换成 64 位
+	hash Hash64
+	// 圆环上最大的位置，由哈希函数的位数决定
+	maxPos uint64
+	// 创建哈希函数的工厂函数，只在创建实例时使用
+	hashFactory func() Hash
+	// 哈希之前添加的命名空间，只在创建实例时使用
+	namespace string
+	// 副本对应的字符串格式，为 nil 时使用 vnodeFormat
+	format VNodeFormatter
+	// 负载上限的系数，为 0 时不限制负载
+	loadFactor float64
+	// 每个节点上分配的 key 的数量
+	loads map[string]int
+	// 已经分配的 key 以及所在的节点
+	assigned map[string]string
+	// 分配的 key 的过期时间，为 0 时不会过期
+	ttl time.Duration
+	// 分配的 key 最近一次被获取的时间，只在设置了过期时间时记录
+	touched map[string]time.Time
+	// 通知过期 key 的 goroutine 退出，以及该 goroutine 已经退出
+	done, stopped chan struct{}
+	closeOnce     sync.Once
+	// 保护事件通道的发送和关闭，关闭之后不再发送事件
+	eventsMu sync.Mutex
+	closed   bool
+	// 通过 Pin 固定分配的 key 以及所在的节点
+	pins map[string]string
+	// 通过 MarkDown 标记为不可用的节点
+	down map[string]bool
+	// Rename 之后节点计算位置使用的标识以及对应的节点，这些标识不能再作为新节点的名称
+	ids map[string]string
+	// 节点添加和删除之后的回调函数
+	onAdd, onRemove func(slot string)
+	// 节点变化的事件
+	events chan Event
+	// 通过 WithInitialNodes 添加的节点
+	initial []string
+	// 通过 WithReplicasPerNode 单独设置的副本数量
+	perNode map[string]int
+	// 子圆环的数量，大于 1 时 New 返回划分之后的实例
+	shards int
+	// 是否为只读的圆环，只能在锁内访问
+	readOnly bool
+	// 是否延迟排序，以及是否有添加之后还没有排序和发布的副本
+	lazy  bool
+	dirty atomic.Bool
+	// 提供给读操作的圆环快照，每次修改圆环之后重新发布
+	// 写操作在锁内修改 circle 和 servers，Get 直接读取快照，不需要加锁
+	snapshot atomic.Pointer[ring]
+	// 查找的 key、添加以及删除的节点的数量，通过 OpStats 获取
+	gets, adds, deletes atomic.Uint64
 	sync.RWMutex
 }
 
 // Add 向哈希圆环中添加一个节点
-func (c *consistent) Add(slot string) {
+// 如果节点已经存在或者名称仍然被 Rename 之后的节点作为标识使用，不做任何处理并返回 false
+func (c *consistent) Add(slot string) bool {
+	c.adds.Add(1)
+	if !c.addNode(slot, c.member(slot)) {
+		return false
+	}
+	c.notifyAdd(slot)
+	return true
+}
+
+// AddBatch 向哈希圆环中批量添加节点，所有节点添加完成之后只进行一次排序
+// 已经存在的节点会被跳过，返回实际添加的节点数量
+func (c *consistent) AddBatch(slots ...string) int {
+	c.adds.Add(uint64(len(slots)))
+	added := c.addBatch(slots)
+	c.notifyAdd(added...)
+	return len(added)
+}
+
+func (c *consistent) addBatch(slots []string) []string {
+	c.Lock()
+	defer c.Unlock()
+	c.writable()
+	added := make([]string, 0, len(slots))
+	for _, slot := range slots {
+		if c.add(slot, c.member(slot)) {
+			added = append(added, slot)
+		}
+	}
+	if len(added) > 0 {
+		c.commit()
+	}
+	return added
+}
+
+// AddWeight 向哈希圆环中添加一个带权重的节点
+// 节点在圆环上拥有 weight * replicas 个副本，
+// 如果节点已经存在或者权重不为正数，不做任何处理并返回 false
+func (c *consistent) AddWeight(slot string, weight int) bool {
+	c.adds.Add(1)
+	if weight <= 0 || !c.addNode(slot, &member{weight: weight}) {
+		return false
+	}
+	c.notifyAdd(slot)
+	return true
+}
+
+// AddWeightFloat 添加一个带小数权重的节点，副本数量为 weight * replicas 四舍五入，至少为 1
+// 副本数量在添加时确定，之后修改全局的副本数量不会影响该节点，节点已经存在或者权重不为正数时返回 false
+func (c *consistent) AddWeightFloat(slot string, weight float64) bool {
+	c.adds.Add(1)
+	if !validWeight(weight) || !c.addScaled(slot, weight) {
+		return false
+	}
+	c.notifyAdd(slot)
+	return true
+}
+
+func (c *consistent) addScaled(slot string, weight float64) bool {
 	c.Lock()
 	defer c.Unlock()
-	c.add(slot)
+	c.writable()
+	if !c.add(slot, c.scaled(weight)) {
+		return false
+	}
+	c.commit()
+	return true
 }
 
-func (c *consistent) hashKey(key string, i int) uint32 {
-	return c.hash(strconv.Itoa(i) + key)
+// validWeight 判断小数权重是否为有限的正数
+func validWeight(weight float64) bool {
+	return weight > 0 && !math.IsInf(weight, 1)
 }
 
-func (c *consistent) add(node string) {
-	for i := 0; i < c.replicas; i++ {
-		key := c.hashKey(node, i)
-		c.circle = append(c.circle, key)
-		c.servers[key] = node
+// scaled 创建带小数权重的节点，副本数量为 weight * replicas 四舍五入，至少为 1
+func (c *consistent) scaled(weight float64) *member {
+	replicas := int(math.Round(weight * float64(c.replicas)))
+	if replicas < 1 {
+		replicas = 1
+	}
+	return &member{weight: 1, replicas: replicas}
+}
+
+// member 创建权重为 1 的节点，使用 WithReplicasPerNode 中为该节点设置的副本数量
+func (c *consistent) member(slot string) *member {
+	if replicas := c.perNode[slot]; replicas > 0 {
+		return &member{weight: 1, replicas: replicas}
+	}
+	return &member{weight: 1}
+}
+
+// AddWithReplicas 向哈希圆环中添加一个节点，使用单独指定的副本数量而不是全局默认的副本数量
+// 修改全局的副本数量不会影响该节点，如果节点已经存在或者副本数量不为正数，不做任何处理并返回 false
+func (c *consistent) AddWithReplicas(slot string, replicas int) bool {
+	c.adds.Add(1)
+	if replicas <= 0 || !c.addNode(slot, &member{weight: 1, replicas: replicas}) {
+		return false
+	}
+	c.notifyAdd(slot)
+	return true
+}
+
+// AddReturningPositions 向哈希圆环中添加一个节点，并返回该节点的副本在圆环上的位置，按照从小到大排序
+// 返回的是解决冲突之后最终的位置，用于排查节点的分布情况，如果节点已经存在，不做任何处理并返回 nil；
+// 之后添加的标识更小的节点可能抢占其中的位置，此时需要通过 RingPositions 重新获取
+func (c *consistent) AddReturningPositions(slot string) []uint64 {
+	c.adds.Add(1)
+	positions := c.addPositions(slot)
+	if positions == nil {
+		return nil
+	}
+	c.notifyAdd(slot)
+	return positions
+}
+
+func (c *consistent) addPositions(slot string) []uint64 {
+	c.Lock()
+	defer c.Unlock()
+	c.writable()
+	m := c.member(slot)
+	if !c.add(slot, m) {
+		return nil
+	}
+	c.commit()
+	positions := make(uints, m.positions.Len())
+	copy(positions, m.positions)
+	sort.Sort(positions)
+	return positions
+}
+
+// addNode 加锁添加一个节点并重新排序
+func (c *consistent) addNode(slot string, m *member) bool {
+	c.Lock()
+	defer c.Unlock()
+	c.writable()
+	if !c.add(slot, m) {
+		return false
 	}
-	// 增加一个节点
-	c.nodes[node] = struct{}{}
 	// 重新进行排序
+	c.commit()
+	return true
+}
+
+// commit 在添加节点之后排序并发布圆环，开启 WithLazySort 时只进行标记，由之后的读操作完成，必须在写锁内调用
+func (c *consistent) commit() {
+	if c.lazy {
+		c.dirty.Store(true)
+		return
+	}
 	sort.Sort(c.circle)
+	c.publish()
+}
+
+// settle 完成延迟的排序并发布圆环，依赖 circle 有序的修改操作需要先调用，必须在写锁内调用
+func (c *consistent) settle() {
+	if c.dirty.Load() {
+		c.publish()
+	}
+}
+
+// flush 加写锁完成延迟的排序，不能在持有锁时调用
+func (c *consistent) flush() {
+	c.Lock()
+	defer c.Unlock()
+	c.settle()
+}
+
+// rlock 加读锁，开启 WithLazySort 时保证加锁之后 circle 已经排序
+func (c *consistent) rlock() {
+	for {
+		c.flush()
+		c.RLock()
+		// 持有读锁时没有修改操作，之后不会再被标记
+		if !c.dirty.Load() {
+			return
+		}
+		c.RUnlock()
+	}
+}
+
+func (c *consistent) hashKey(key string, i int) uint64 {
+	if c.format == nil {
+		return c.hash(vnodeFormat(key, i))
+	}
+	return c.hash(c.format(key, i))
+}
+
+// probeKey 计算节点第 i 个副本第 probe 次冲突探测的位置，自定义副本格式时对节点名称加盐
+func (c *consistent) probeKey(id string, i, probe int) uint64 {
+	if c.format == nil {
+		return c.hash(vnodeKey(id, i, probe))
+	}
+	return c.hash(c.format(id+"#"+strconv.Itoa(probe), i))
+}
+
+// add 向圆环中添加节点，并且记录节点的副本在圆环上的位置
+// 为了批量添加时只需要排序一次，这里不对圆环进行排序，由调用者负责
+func (c *consistent) add(node string, m *member) bool {
+	// 节点已经存在，重复添加会导致圆环上出现重复的副本
+	if _, ok := c.nodes[node]; ok {
+		return false
+	}
+	// 名称仍然是其他节点 Rename 之前的标识，两者的副本位置完全相同
+	if _, ok := c.ids[node]; ok {
+		return false
+	}
+	// 增加一个节点
+	c.nodes[node] = m
+	c.reserve(node, m)
+	c.place(node, m)
+	return true
+}
+
+// reserve 记录 Rename 之后的节点所使用的标识
+func (c *consistent) reserve(node string, m *member) {
+	if m.id == "" {
+		return
+	}
+	if c.ids == nil {
+		c.ids = make(map[string]string)
+	}
+	c.ids[m.id] = node
+}
+
+// place 计算节点所有副本的位置并添加到圆环中
+// 圆环上的位置是唯一的，与其他节点的副本冲突时，标识 (节点名称，Rename 之后为原来的名称) 较小的节点保留该位置，
+// 较大的节点重新探测，所以冲突的结果与节点添加的顺序无关，每次重新构建圆环都会得到相同的结果，
+// 位置被抢占的节点会重新计算所有副本的位置
+func (c *consistent) place(node string, m *member) {
+	replicas := m.count(c.replicas)
+	m.positions = make(uints, 0, replicas)
+	m.indices = nil
+	m.displaced = false
+	id := m.key(node)
+	var evicted []string
+	for i := 0; i < replicas; i++ {
+		key, owner, blocked, ok := c.position(id, i)
+		if blocked {
+			m.displaced = true
+		}
+		if !ok {
+			if m.indices == nil {
+				m.indices = make([]int, len(m.positions), replicas)
+				for k := range m.indices {
+					m.indices[k] = k
+				}
+			}
+			continue
+		}
+		if owner == "" {
+			c.circle = append(c.circle, key)
+		} else {
+			evicted = append(evicted, owner)
+		}
+		c.servers[key] = node
+		m.positions = append(m.positions, key)
+		if m.indices != nil {
+			m.indices = append(m.indices, i)
+		}
+	}
+	seen := make(map[string]struct{}, len(evicted))
+	for _, owner := range evicted {
+		if _, ok := seen[owner]; ok {
+			continue
+		}
+		seen[owner] = struct{}{}
+		c.replace(owner)
+	}
+}
+
+// replace 移除节点剩余的副本，然后重新计算所有副本的位置
+func (c *consistent) replace(node string) {
+	m := c.nodes[node]
+	memo := getMemo()
+	defer putMemo(memo)
+	for _, key := range m.positions {
+		// 被抢占的位置已经属于其他节点
+		if c.servers[key] == node {
+			delete(c.servers, key)
+			memo[key] = struct{}{}
+		}
+	}
+	c.compact(memo)
+	c.place(node, m)
+}
+
+// compact 从圆环中移除 memo 中的位置，保留其余位置原来的顺序
+func (c *consistent) compact(memo map[uint64]struct{}) {
+	if len(memo) == 0 {
+		return
+	}
+	j := 0
+	for _, key := range c.circle {
+		if _, ok := memo[key]; ok {
+			continue
+		}
+		c.circle[j] = key
+		j++
+	}
+	c.circle = c.circle[:j]
+}
+
+// 发生冲突时最多重新探测的次数
+const maxProbes = 64
+
+// position 计算标识为 id 的节点第 i 个副本在圆环上的位置
+// 如果该位置已经被标识更小的节点 (或者自身的其他副本) 占用，加盐之后重新计算，直到找到可以使用的位置，
+// 被标识更大的节点占用时抢占该位置，并返回原来的节点，探测 maxProbes 次仍然冲突则放弃该副本，返回 false，
+// blocked 表示探测过程中是否遇到过其他节点占用的位置
+func (c *consistent) position(id string, i int) (key uint64, owner string, blocked, ok bool) {
+	key = c.initialPosition(id, i)
+	for probe := 1; ; probe++ {
+		owner, ok = c.servers[key]
+		if !ok {
+			return key, "", blocked, true
+		}
+		other := c.nodes[owner].key(owner)
+		if other > id {
+			return key, owner, blocked, true
+		}
+		if other != id {
+			blocked = true
+		}
+		if probe > maxProbes {
+			return 0, "", blocked, false
+		}
+		key = c.probeKey(id, i, probe)
+	}
+}
+
+// initialPosition 计算标识为 id 的节点第 i 个副本在没有冲突时的位置
+func (c *consistent) initialPosition(id string, i int) uint64 {
+	if i == 0 && c.replicas == 1 && c.format == nil {
+		// 没有虚拟节点时直接使用节点名称的哈希值，不需要拼接副本的编号
+		return c.hash(id)
+	}
+	return c.hashKey(id, i)
 }
 
 // Get 获取到属于的server结点
+// 如果圆环上没有任何节点，返回空字符串
 func (c *consistent) Get(name string) string {
-	c.RLock()
+	server, _ := c.GetOK(name)
+	return server
+}
+
+// GetCtx 与 Get 一致，查找的时间复杂度只有 O(log n)，所以只在开始时检查一次 ctx，
+// ctx 已经取消时返回 ctx.Err()
+func (c *consistent) GetCtx(ctx context.Context, name string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return c.Get(name), nil
+}
+
+// GetOK 获取到属于的server结点
+// 如果圆环上没有任何节点，返回 false
+func (c *consistent) GetOK(name string) (string, bool) {
+	c.gets.Add(1)
+	// 首先将hash找到，然后在Hash圆环上找到对应的节点
+	return c.load().lookup(name, c.hash)
+}
+
+// GetMany 批量获取 keys 所属的server结点，结果与 keys 的顺序一致
+// 所有的 key 都在同一个圆环快照上查找，没有任何节点时结果均为空字符串
+func (c *consistent) GetMany(keys []string) []string {
+	res := make([]string, len(keys))
+	c.GetManyInto(keys, res)
+	return res
+}
+
+// GetManyInto 与 GetMany 一致，但是将结果写入调用者提供的 out 中，重复使用 out 可以避免每次分配结果
+// out[i] 为 keys[i] 所属的节点，只写入前 min(len(keys), len(out)) 个结果并返回写入的数量，
+// out 比 keys 短时之后的 key 不会被查找，需要使用剩余的 keys 再次调用，out 中之后的元素保持不变
+func (c *consistent) GetManyInto(keys []string, out []string) int {
+	n := len(keys)
+	if len(out) < n {
+		n = len(out)
+	}
+	c.gets.Add(uint64(n))
+	r := c.load()
+	for i, key := range keys[:n] {
+		out[i], _ = r.lookup(key, c.hash)
+	}
+	return n
+}
+
+// GroupByNode 按照所属的节点对 keys 进行分组，是 GetMany 的反向分组，便于按照节点批量发送请求
+// 所有的 key 都在同一个圆环快照上查找，每个分组中 key 的顺序与 keys 一致，没有任何节点时返回空的结果
+func (c *consistent) GroupByNode(keys []string) map[string][]string {
+	c.gets.Add(uint64(len(keys)))
+	r := c.load()
+	res := make(map[string][]string, r.nodes)
+	for _, key := range keys {
+		if server, ok := r.lookup(key, c.hash); ok {
+			res[server] = append(res[server], key)
+		}
+	}
+	return res
+}
+
+// GetWithPos 获取到属于的server结点，以及 key 在圆环上落到的副本位置
+// 如果 key 的哈希值大于圆环上最后一个位置，会顺时针绕回到第一个位置 circle[0]，
+// 固定分配的 key 返回固定的节点以及顺时针方向该节点的第一个副本位置，如果圆环上没有任何节点，返回空字符串和 0
+func (c *consistent) GetWithPos(name string) (string, uint64) {
+	c.gets.Add(1)
+	r := c.load()
+	if r.circle.Len() == 0 {
+		return "", 0
+	}
+	i := r.circle.search(c.hash(name))
+	if pinned, ok := r.pins[name]; ok {
+		for j := 0; j < r.circle.Len(); j++ {
+			pos := r.circle[(i+j)%r.circle.Len()]
+			if r.servers[pos] == pinned {
+				return pinned, pos
+			}
+		}
+	}
+	pos := r.circle[i]
+	return r.servers[pos], pos
+}
+
+// GetReplica 获取 key 所属的节点以及 key 落在该节点的第几个副本上，圆环上没有任何节点时返回空字符串和 -1
+// 按照圆环的位置查找，不考虑 Pin 固定分配的节点
+func (c *consistent) GetReplica(key string) (node string, replicaIndex int) {
+	c.gets.Add(1)
+	c.rlock()
 	defer c.RUnlock()
-	// 首先将hash找到
-	key := c.hash(name)
-	// 然后在Hash圆环上找到对应的节点
-	i := sort.Search(len(c.circle), func(i int) bool { return c.circle[i] >= key })
-	if i >= c.circle.Len() {
-		i = 0
+	if c.circle.Len() == 0 {
+		return "", -1
+	}
+	pos := c.circle[c.circle.search(c.hash(key))]
+	node = c.servers[pos]
+	m := c.nodes[node]
+	for k, p := range m.positions {
+		if p == pos {
+			return node, m.replica(k)
+		}
+	}
+	return node, -1
+}
+
+// Position 表示圆环上的一个副本位置以及所属的节点
+type Position struct {
+	Node string
+	Pos  uint64
+}
+
+// ClosestNodes 获取从 key 所在位置开始顺时针方向的 n 个副本位置以及所属的节点，按照与 key 的距离排序
+// 与 GetN 不同，这里不会跳过同一个物理节点的其他副本，结果中同一个节点可能出现多次，
+// 超过最后一个位置时绕回到 circle[0]，副本不足 n 个时返回所有的副本
+func (c *consistent) ClosestNodes(name string, n int) []Position {
+	c.gets.Add(1)
+	r := c.load()
+	if n <= 0 || r.circle.Len() == 0 {
+		return nil
+	}
+	if n > r.circle.Len() {
+		n = r.circle.Len()
+	}
+	res := make([]Position, n)
+	start := r.circle.search(c.hash(name))
+	for i := range res {
+		pos := r.circle[(start+i)%r.circle.Len()]
+		res[i] = Position{Node: r.servers[pos], Pos: pos}
+	}
+	return res
+}
+
+// Neighbors 获取 key 所属的节点 owner，以及圆环上 owner 逆时针和顺时针方向最近的其他物理节点
+// 不考虑 Pin 固定分配的节点，没有其他节点时 prev 和 next 为空字符串
+func (c *consistent) Neighbors(key string) (prev, owner, next string) {
+	c.gets.Add(1)
+	r := c.load()
+	if r.circle.Len() == 0 {
+		return "", "", ""
+	}
+	start := r.circle.search(c.hash(key))
+	owner = r.servers[r.circle[start]]
+	for j := 1; j < r.circle.Len(); j++ {
+		if server := r.servers[r.circle[(start+j)%r.circle.Len()]]; server != owner {
+			next = server
+			break
+		}
+	}
+	for j := 1; j < r.circle.Len(); j++ {
+		server := r.servers[r.circle[(start-j+r.circle.Len())%r.circle.Len()]]
+		if server != owner && (server != next || r.nodes < 3) {
+			prev = server
+			break
+		}
+	}
+	return prev, owner, next
+}
+
+// load 获取当前发布的圆环快照，有延迟的排序时先完成排序，持有锁时只能在 rlock 或者 settle 之后调用
+func (c *consistent) load() *ring {
+	if c.dirty.Load() {
+		c.flush()
+	}
+	if r := c.snapshot.Load(); r != nil {
+		return r
 	}
-	return c.servers[c.circle[i]]
+	return emptyRing
 }
 
-// Delete 删除一个节点
+// publish 发布当前圆环的快照，必须在写锁内调用
+func (c *consistent) publish() {
+	if c.dirty.Load() {
+		sort.Sort(c.circle)
+		c.dirty.Store(false)
+	}
+	r := &ring{
+		circle:  make(uints, c.circle.Len()),
+		servers: make(map[uint64]string, len(c.servers)),
+		nodes:   len(c.nodes),
+	}
+	copy(r.circle, c.circle)
+	for key, server := range c.servers {
+		r.servers[key] = server
+	}
+	for key, node := range c.pins {
+		if _, ok := c.nodes[node]; ok {
+			if r.pins == nil {
+				r.pins = make(map[string]string)
+			}
+			r.pins[key] = node
+		}
+	}
+	for node := range c.down {
+		if _, ok := c.nodes[node]; ok {
+			if r.down == nil {
+				r.down = make(map[string]bool)
+			}
+			r.down[node] = true
+		}
+	}
+	c.snapshot.Store(r)
+}
+
+// GetN 获取到数据对应的 n 个不同的物理节点
+// 从 key 所在位置开始顺时针遍历，跳过已经选中的物理节点的副本，超过最后一个位置时绕回到 circle[0]，
+// 最多遍历圆环一圈，所以无论 key 落在哪里都返回 min(n, 节点数量) 个不同的节点，
+// 第一个元素与 Get 的结果一致，如果物理节点不足 n 个，按照圆环顺序返回所有节点
+func (c *consistent) GetN(name string, n int) []string {
+	c.gets.Add(1)
+	return c.getN(name, n)
+}
+
+// getN 与 GetN 一致，但是不计入 OpStats
+func (c *consistent) getN(name string, n int) []string {
+	r := c.load()
+	if n <= 0 || r.circle.Len() == 0 {
+		return nil
+	}
+	if n > r.nodes {
+		n = r.nodes
+	}
+	res := make([]string, 0, n)
+	r.walkFrom(name, c.hash, func(server string) bool {
+		res = append(res, server)
+		return len(res) < n
+	})
+	return res
+}
+
+// GetNFiltered 获取到数据对应的 n 个节点，并且任意两个节点的 domain(node) 都不相同，例如不在同一个机架上
+// 与 GetN 一样从 key 所在位置开始顺时针遍历，跳过已经选中的物理节点以及已经选中的故障域中的节点，
+// 第一个元素与 Get 的结果一致；故障域不足 n 个时，每个故障域只返回顺时针方向遇到的第一个节点
+func (c *consistent) GetNFiltered(name string, n int, domain func(node string) string) []string {
+	c.gets.Add(1)
+	r := c.load()
+	if n <= 0 || r.circle.Len() == 0 {
+		return nil
+	}
+	var res []string
+	domains := make(map[string]struct{}, n)
+	r.walkFrom(name, c.hash, func(server string) bool {
+		d := domain(server)
+		if _, ok := domains[d]; ok {
+			return true
+		}
+		domains[d] = struct{}{}
+		res = append(res, server)
+		return len(res) < n
+	})
+	return res
+}
+
+// Successor 获取 key 顺时针方向的第一个节点，与 Get 一致
+func (c *consistent) Successor(name string) string {
+	return c.Get(name)
+}
+
+// Predecessor 获取 key 逆时针方向的第一个节点，也就是 key 所在位置之前的一个副本所属的节点
+// key 位于 circle[0] 之前 (或者之后绕回到 circle[0]) 时，返回圆环上最后一个副本所属的节点，
+// distinct 为 true 时跳过与 Successor 属于同一个物理节点的副本，只有一个物理节点时返回该节点
+func (c *consistent) Predecessor(name string, distinct bool) string {
+	c.gets.Add(1)
+	r := c.load()
+	n := r.circle.Len()
+	if n == 0 {
+		return ""
+	}
+	i := r.circle.search(c.hash(name))
+	successor := r.servers[r.circle[i]]
+	for j := 1; j <= n; j++ {
+		server := r.servers[r.circle[(i-j+n)%n]]
+		if !distinct || server != successor {
+			return server
+		}
+	}
+	return successor
+}
+
+// GetTwo 获取 key 的主节点以及顺时针方向下一个不同的物理节点作为备份节点
+// 只有一个物理节点时备份节点与主节点相同，没有任何节点时均为空字符串
+func (c *consistent) GetTwo(name string) (string, string) {
+	nodes := c.GetN(name, 2)
+	switch len(nodes) {
+	case 0:
+		return "", ""
+	case 1:
+		return nodes[0], nodes[0]
+	default:
+		return nodes[0], nodes[1]
+	}
+}
+
+// WalkFrom 从 key 所在的位置开始顺时针遍历圆环上的物理节点
+// 第一个节点与 Get 的结果一致，之后每个物理节点只会访问一次，最多绕圆环一圈，
+// fn 返回 false 时停止遍历
+func (c *consistent) WalkFrom(name string, fn func(node string) bool) {
+	c.gets.Add(1)
+	r := c.load()
+	if r.circle.Len() == 0 {
+		return
+	}
+	r.walkFrom(name, c.hash, fn)
+}
+
+// walkFrom 从 key 所在的位置开始顺时针遍历不同的物理节点，固定分配的 key 首先访问固定的节点
+func (r *ring) walkFrom(name string, hash Hash64, fn func(server string) bool) {
+	pinned, ok := r.pins[name]
+	if ok && !fn(pinned) {
+		return
+	}
+	r.walk(hash(name), func(server string) bool {
+		if ok && server == pinned {
+			return true
+		}
+		return fn(server)
+	})
+}
+
+// walk 从哈希值 key 所在的位置开始顺时针遍历不同的物理节点
+func (r *ring) walk(key uint64, fn func(server string) bool) {
+	// 记录已经访问过的物理节点
+	seen := make(map[string]struct{}, r.nodes)
+	start := r.circle.search(key)
+	// 最多绕圆环一圈
+	for j := 0; j < r.circle.Len() && len(seen) < r.nodes; j++ {
+		server := r.servers[r.circle[(start+j)%r.circle.Len()]]
+		if _, ok := seen[server]; ok {
+			continue
+		}
+		seen[server] = struct{}{}
+		if !fn(server) {
+			return
+		}
+	}
+}
+
+// Delete 删除一个节点，节点不存在时不做任何处理
 func (c *consistent) Delete(node string) {
+	c.DeleteOK(node)
+}
+
+// DeleteOK 删除一个节点，返回节点是否存在并且被删除
+func (c *consistent) DeleteOK(node string) bool {
+	c.deletes.Add(1)
+	if !c.deleteNode(node) {
+		return false
+	}
+	c.notifyRemove(node)
+	return true
+}
+
+// deleteNode 加锁删除一个节点，返回节点是否存在
+func (c *consistent) deleteNode(node string) bool {
 	c.Lock()
 	defer c.Unlock()
+	c.writable()
+	if !c.delete(node) {
+		return false
+	}
+	c.publish()
+	return true
+}
+
+// delete 从圆环中删除节点的所有副本
+func (c *consistent) delete(node string) bool {
+	m, ok := c.nodes[node]
+	if !ok {
+		return false
+	}
 	// 删除节点
 	delete(c.nodes, node)
-
-	// 因为在数组中删除元素不方便，这里先记录一下需要删除的数据
-	// 然后如果在这里面的数据就不再添加到新的记录中
-	memo := make(map[uint32]struct{})
+	delete(c.ids, m.id)
 
 	// 删除hash圆环中的值
-	for i := 0; i < c.replicas; i++ {
-		key := c.hashKey(node, i)
-		memo[key] = struct{}{}
+	for _, key := range m.positions {
 		delete(c.servers, key)
 	}
+	c.remove(m.positions)
+	c.releaseNode(node)
+	c.reposition()
+	return true
+}
+
+// DeleteBatch 批量删除节点，收集所有需要删除的位置之后只对圆环进行一次压缩
+// 不存在的节点会被跳过，返回实际删除的节点数量
+func (c *consistent) DeleteBatch(slots ...string) int {
+	c.deletes.Add(uint64(len(slots)))
+	removed := c.deleteBatch(slots)
+	c.notifyRemove(removed...)
+	return len(removed)
+}
+
+// deleteBatch 批量删除节点，返回实际删除的节点
+func (c *consistent) deleteBatch(slots []string) []string {
+	c.Lock()
+	defer c.Unlock()
+	c.writable()
+	return c.removeNodes(slots)
+}
+
+// DeleteWhere 删除所有满足 pred 的节点，例如整个下线的网段，返回删除的节点数量
+// 在同一个写锁内选出节点并删除，与 DeleteBatch 一致只对圆环进行一次压缩，
+// pred 在锁内按照节点名称的顺序调用，不能再调用该实例的方法，删除通知同样按照名称的顺序触发
+func (c *consistent) DeleteWhere(pred func(slot string) bool) int {
+	removed := c.deleteWhere(pred)
+	c.deletes.Add(uint64(len(removed)))
+	c.notifyRemove(removed...)
+	return len(removed)
+}
+
+func (c *consistent) deleteWhere(pred func(slot string) bool) []string {
+	c.Lock()
+	defer c.Unlock()
+	c.writable()
+	var slots []string
+	for _, node := range c.sortedMembers() {
+		if pred(node) {
+			slots = append(slots, node)
+		}
+	}
+	return c.removeNodes(slots)
+}
+
+// removeNodes 删除节点并压缩圆环，返回实际删除的节点，必须在写锁内调用
+func (c *consistent) removeNodes(slots []string) []string {
+	var removed []string
+	memo := getMemo()
+	defer putMemo(memo)
+	for _, node := range slots {
+		m, ok := c.nodes[node]
+		if !ok {
+			continue
+		}
+		delete(c.nodes, node)
+		delete(c.ids, m.id)
+		for _, key := range m.positions {
+			delete(c.servers, key)
+			memo[key] = struct{}{}
+		}
+		c.releaseNode(node)
+		removed = append(removed, node)
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	c.compact(memo)
+	c.reposition()
+	c.publish()
+	return removed
+}
+
+// reposition 重新计算因为冲突重新探测过的节点的位置，删除节点之后调用，必须在写锁内调用
+// 被删除的节点占用的位置空出来之后，这些节点可以回到原来的位置，与只使用剩余节点重新构建圆环的结果一致
+func (c *consistent) reposition() {
+	var nodes []string
+	for node, m := range c.nodes {
+		if m.displaced {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		return
+	}
+	sort.Strings(nodes)
+	memo := getMemo()
+	defer putMemo(memo)
+	for _, node := range nodes {
+		for _, key := range c.nodes[node].positions {
+			if c.servers[key] == node {
+				delete(c.servers, key)
+				memo[key] = struct{}{}
+			}
+		}
+	}
+	c.compact(memo)
+	for _, node := range nodes {
+		c.place(node, c.nodes[node])
+	}
+	sort.Sort(c.circle)
+}
 
-	// 创建一个新的保存
-	newCircle := make(uints, 0, c.circle.Len()-c.replicas)
-	for i := 0; i < c.circle.Len(); i++ {
-		if _, ok := memo[c.circle[i]]; !ok {
-			newCircle = append(newCircle, c.circle[i])
+// Rename 修改节点的名称，副本位置保持不变，不会有任何 key 发生迁移，old 不存在或者 new 已经被使用时返回 false
+// 原来的名称作为节点的标识继续保留，在节点删除之前不能再作为其他节点的名称
+func (c *consistent) Rename(old, new string) bool {
+	if !c.rename(old, new) {
+		return false
+	}
+	c.notifyRemove(old)
+	c.notifyAdd(new)
+	return true
+}
+
+func (c *consistent) rename(old, new string) bool {
+	c.Lock()
+	defer c.Unlock()
+	c.writable()
+	m, ok := c.nodes[old]
+	if !ok {
+		return false
+	}
+	if _, ok := c.nodes[new]; ok {
+		return false
+	}
+	id := m.key(old)
+	if owner, ok := c.ids[new]; ok && owner != old {
+		// new 是其他节点 Rename 之前的标识
+		return false
+	}
+	delete(c.ids, m.id)
+	m.id = id
+	if m.id == new {
+		// 改回了原来的名称
+		m.id = ""
+	}
+	delete(c.nodes, old)
+	c.nodes[new] = m
+	c.reserve(new, m)
+	for _, key := range m.positions {
+		c.servers[key] = new
+	}
+	// 分配到该节点上的 key 转移到新的名称下
+	if load, ok := c.loads[old]; ok {
+		delete(c.loads, old)
+		c.loads[new] = load
+		for name, server := range c.assigned {
+			if server == old {
+				c.assigned[name] = new
+			}
+		}
+	}
+	// 固定到该节点上的 key 同样转移到新的名称下
+	for key, node := range c.pins {
+		if node == old {
+			c.pins[key] = new
 		}
 	}
-	c.circle = newCircle
+	// 不可用的标记跟随节点
+	if c.down[old] {
+		delete(c.down, old)
+		c.down[new] = true
+	}
+	c.publish()
+	return true
+}
+
+// 删除节点时记录需要删除的下标，重复使用之后 remove 不需要分配内存
+var indicesPool = sync.Pool{
+	New: func() interface{} {
+		indices := make([]int, 0, 64)
+		return &indices
+	},
+}
+
+// 批量删除时记录需要删除的位置，重复使用之前需要清空
+var memoPool = sync.Pool{
+	New: func() interface{} {
+		return make(map[uint64]struct{})
+	},
+}
+
+// getMemo 从 memoPool 中获取一个空的集合
+func getMemo() map[uint64]struct{} {
+	return memoPool.Get().(map[uint64]struct{})
 }
 
-// Members 获取到所有的节点
+// putMemo 清空集合之后放回 memoPool
+func putMemo(memo map[uint64]struct{}) {
+	for key := range memo {
+		delete(memo, key)
+	}
+	memoPool.Put(memo)
+}
+
+// remove 从圆环中移除指定的位置
+// 圆环是有序的，通过二分查找找到每个位置的下标，然后原地压缩一次即可，不需要重新分配
+func (c *consistent) remove(positions uints) {
+	c.settle()
+	p := indicesPool.Get().(*[]int)
+	defer func() {
+		*p = (*p)[:0]
+		indicesPool.Put(p)
+	}()
+	indices := *p
+	for _, key := range positions {
+		i := c.circle.lowerBound(key)
+		if i < c.circle.Len() && c.circle[i] == key {
+			indices = append(indices, i)
+		}
+	}
+	*p = indices
+	if len(indices) == 0 {
+		return
+	}
+	sort.Ints(indices)
+
+	// 从第一个需要删除的下标开始，将保留的元素依次前移
+	j, k := indices[0], 0
+	for i := indices[0]; i < c.circle.Len(); i++ {
+		if k < len(indices) && indices[k] == i {
+			k++
+			continue
+		}
+		c.circle[j] = c.circle[i]
+		j++
+	}
+	c.circle = c.circle[:j]
+}
+
+// Members 获取到所有的节点，按照名称排序
 func (c *consistent) Members() []string {
 	c.RLock()
 	defer c.RUnlock()
-	res := make([]string, 0, len(c.nodes))
-	for k := range c.nodes {
-		res = append(res, k)
+	return c.sortedMembers()
+}
+
+// MembersWithReplicas 获取到所有的节点以及每个节点当前在圆环上的副本数量
+// 在读锁内一次性获取，返回的结果是同一时刻的状态，冲突丢弃的副本不计算在内
+func (c *consistent) MembersWithReplicas() map[string]int {
+	c.RLock()
+	defer c.RUnlock()
+	res := make(map[string]int, len(c.nodes))
+	for node, m := range c.nodes {
+		res[node] = len(m.positions)
 	}
 	return res
 }
 
+// SetReplicas 修改副本数量，并使用新的副本数量重新构建圆环
+// 带权重的节点仍然保持原有的权重，通过 AddWithReplicas 添加的节点保持原有的副本数量，
+// 副本数量没有变化时不做任何处理
+func (c *consistent) SetReplicas(count int) error {
+	if count <= 0 {
+		return ErrInvalidReplicas
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if count == c.replicas {
+		return nil
+	}
+	c.replicas = count
+	c.rebuild()
+	return nil
+}
+
+// rebuild 根据现有的节点重新构建圆环
+// 按照节点名称的顺序依次添加，保证发生冲突时结果是确定的
+func (c *consistent) rebuild() {
+	nodes := c.nodes
+	names := make([]string, 0, len(nodes))
+	for node := range nodes {
+		names = append(names, node)
+	}
+	sort.Strings(names)
+
+	c.nodes = make(map[string]*member, len(nodes))
+	c.ids = nil
+	c.servers = make(map[uint64]string)
+	c.circle = make(uints, 0)
+	for _, node := range names {
+		m := nodes[node]
+		c.add(node, &member{weight: m.weight, replicas: m.replicas, id: m.id})
+	}
+	sort.Sort(c.circle)
+	c.publish()
+}
+
+// Contains 判断节点是否存在
+func (c *consistent) Contains(node string) bool {
+	c.RLock()
+	defer c.RUnlock()
+	_, ok := c.nodes[node]
+	return ok
+}
+
+// Len 获取物理节点的数量，不包括圆环上的副本
+func (c *consistent) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.nodes)
+}
+
+// VirtualNodes 获取圆环上副本的数量
+// 冲突探测失败而被丢弃的副本不计算在内，因此可能小于 Len() * replicas
+func (c *consistent) VirtualNodes() int {
+	c.RLock()
+	defer c.RUnlock()
+	return c.circle.Len()
+}
+
+// Close 停止 WithBoundedLoadTTL 启动的 goroutine 并等待其退出，然后关闭 Events 返回的事件通道，
+// 可以重复调用，总是返回 nil；Close 之后不应该再使用该实例，Get, Add 和 Delete 等操作的结果未定义，
+// 节点的变化不会再产生事件，分配的 key 也不会再过期
+func (c *consistent) Close() error {
+	c.closeOnce.Do(func() {
+		if c.done != nil {
+			close(c.done)
+			<-c.stopped
+		}
+		c.closeEvents()
+	})
+	return nil
+}
+
+// IsEmpty 判断圆环上是否没有任何节点
+func (c *consistent) IsEmpty() bool {
+	return c.Len() == 0
+}
+
+// Reset 删除所有的节点以及固定分配的 key，保留副本数量和哈希函数等配置，便于重复使用
+func (c *consistent) Reset() {
+	c.notifyRemove(c.reset()...)
+}
+
+// reset 清空圆环，返回被删除的节点
+func (c *consistent) reset() []string {
+	c.Lock()
+	defer c.Unlock()
+	c.writable()
+	removed := c.sortedMembers()
+	c.nodes = make(map[string]*member)
+	c.servers = make(map[uint64]string)
+	c.circle = make(uints, 0)
+	c.loads = make(map[string]int)
+	c.assigned = make(map[string]string)
+	if c.touched != nil {
+		c.touched = make(map[string]time.Time)
+	}
+	c.pins = nil
+	c.down = nil
+	c.ids = nil
+	c.publish()
+	return removed
+}
+
+// Clone 复制一个完全独立的实例，之后对任意一个实例的修改都不会影响另一个
+func (c *consistent) Clone() ConsistentHasher {
+	c.rlock()
+	defer c.RUnlock()
+	return c.clone()
+}
+
+func (c *consistent) clone() *consistent {
+	nc := &consistent{
+		replicas: c.replicas,
+		nodes:    make(map[string]*member, len(c.nodes)),
+		servers:  make(map[uint64]string, len(c.servers)),
+		circle:   make(uints, c.circle.Len()),
+		hash:     c.hash,
+		maxPos:   c.maxPos,
+		format:   c.format,
+		perNode:  c.perNode,
+		lazy:     c.lazy,
+
+		loadFactor: c.loadFactor,
+		loads:      make(map[string]int),
+		assigned:   make(map[string]string),
+		events:     make(chan Event, eventBuffer),
+	}
+	for key, node := range c.pins {
+		if nc.pins == nil {
+			nc.pins = make(map[string]string, len(c.pins))
+		}
+		nc.pins[key] = node
+	}
+	for node := range c.down {
+		if nc.down == nil {
+			nc.down = make(map[string]bool, len(c.down))
+		}
+		nc.down[node] = true
+	}
+	for node, m := range c.nodes {
+		nc.nodes[node] = &member{weight: m.weight, replicas: m.replicas, id: m.id, positions: append(uints(nil), m.positions...), indices: append([]int(nil), m.indices...), displaced: m.displaced}
+		nc.reserve(node, nc.nodes[node])
+	}
+	for key, server := range c.servers {
+		nc.servers[key] = server
+	}
+	copy(nc.circle, c.circle)
+	nc.publish()
+	return nc
+}
+
+// notifyAdd 通知节点已经添加，必须在锁外调用
+func (c *consistent) notifyAdd(nodes ...string) {
+	for _, node := range nodes {
+		c.emit(Event{Type: Added, Node: node})
+		if c.onAdd != nil {
+			c.onAdd(node)
+		}
+	}
+}
+
+// notifyRemove 通知节点已经删除，必须在锁外调用
+func (c *consistent) notifyRemove(nodes ...string) {
+	for _, node := range nodes {
+		c.emit(Event{Type: Removed, Node: node})
+		if c.onRemove != nil {
+			c.onRemove(node)
+		}
+	}
+}
+
 // New 创建新的一致性哈希实例
+// 副本数量不为正数时所有的 Get 都会返回空字符串，属于使用错误，这里直接 panic
 func New(options ...Option) ConsistentHasher {
+	c := config(options...)
+	if c.replicas <= 0 {
+		panic(ErrInvalidReplicas)
+	}
+	if c.shards > 1 {
+		return newSharded(c, c.shards, options)
+	}
+	c.nodes = make(map[string]*member)
+	c.servers = make(map[uint64]string)
+	c.circle = make(uints, 0)
+	c.loads = make(map[string]int)
+	c.assigned = make(map[string]string)
+	c.events = make(chan Event, eventBuffer)
+	for node, weight := range c.weights {
+		if weight > 0 {
+			c.add(node, &member{weight: weight})
+		}
+	}
+	for node, weight := range c.floatWeights {
+		if validWeight(weight) {
+			c.add(node, c.scaled(weight))
+		}
+	}
+	for _, node := range c.initial {
+		c.add(node, c.member(node))
+	}
+	sort.Sort(c.circle)
+	c.publish()
+	if c.loadFactor > 0 && c.ttl > 0 {
+		c.startReaper()
+	}
+	return c
+}
+
+// config 使用默认配置以及参数选项创建实例，不初始化圆环
+// 其他的实现也通过该方法获取到参数选项中的配置
+func config(options ...Option) *consistent {
 	c := &consistent{
-		nodes:    make(map[string]struct{}),
-		servers:  make(map[uint32]string),
-		circle:   make([]uint32, 0),
 		replicas: 20,
-		hash:     hash,
 	}
+	WithHash(hash)(c)
 	for _, option := range options {
 		option(c)
 	}
+	if c.hashFactory != nil {
+		WithHash(c.hashFactory())(c)
+		c.hashFactory = nil
+	}
+	if c.namespace != "" {
+		// 使用长度作为前缀，不同的 (ns, name) 不会拼接成相同的字符串
+		h, prefix := c.hash, strconv.Itoa(len(c.namespace))+":"+c.namespace
+		c.hash = func(name string) uint64 {
+			return h(prefix + name)
+		}
+	}
 	return c
 }
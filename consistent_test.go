@@ -1,13 +1,29 @@
 package consistent
 
 import (
+	"crypto/md5"
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
+	"math"
 	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+// md5Hash 分布均匀的哈希函数，用于测试节点分布情况
+func md5Hash(name string) uint32 {
+	sum := md5.Sum([]byte(name))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
 func TestConsistentHash(t *testing.T) {
-	c := New(WithReplicas(20))
+	c := New(WithReplicas(20)).(*consistent)
 	ips := []string{"192.168.0.1", "192.168.0.2", "192.168.0.3", "192.168.0.4"}
 
 	for _, ip := range ips {
@@ -20,7 +36,1171 @@ func TestConsistentHash(t *testing.T) {
 		statistic[c.Get(key)]++
 	}
 
+	members := c.Members()
+	if !reflect.DeepEqual(members, ips) {
+		t.Fatalf("Members() = %v, want %v", members, ips)
+	}
+	for _, member := range members {
+		t.Logf("%s: %d", member, statistic[member])
+	}
+}
+
+func TestGetEmpty(t *testing.T) {
+	c := New().(*consistent)
+	if server := c.Get("key"); server != "" {
+		t.Fatalf("Get on empty ring = %q, want empty", server)
+	}
+	if _, ok := c.GetOK("key"); ok {
+		t.Fatal("GetOK on empty ring returned true")
+	}
+}
+
+func TestGetSingleNode(t *testing.T) {
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if server := c.Get(key); server != "192.168.0.1" {
+			t.Fatalf("Get(%q) = %q, want 192.168.0.1", key, server)
+		}
+		if server, ok := c.GetOK(key); !ok || server != "192.168.0.1" {
+			t.Fatalf("GetOK(%q) = (%q, %v), want (192.168.0.1, true)", key, server, ok)
+		}
+	}
+}
+
+func TestAddIdempotent(t *testing.T) {
+	c := New().(*consistent)
+	if !c.Add("192.168.0.1") {
+		t.Fatal("first Add returned false")
+	}
+	for i := 0; i < 100; i++ {
+		if c.Add("192.168.0.1") {
+			t.Fatal("duplicate Add returned true")
+		}
+	}
+	if n := len(c.Members()); n != 1 {
+		t.Fatalf("len(Members()) = %d, want 1", n)
+	}
+	if n := c.circle.Len(); n != c.replicas {
+		t.Fatalf("len(circle) = %d, want %d", n, c.replicas)
+	}
+}
+
+func TestDeleteReAdd(t *testing.T) {
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	c.Delete("192.168.0.1")
+	if !c.Add("192.168.0.1") {
+		t.Fatal("Add after Delete returned false")
+	}
+	if c.Add("192.168.0.1") {
+		t.Fatal("duplicate Add after Delete returned true")
+	}
+
+	// 圆环上不能残留任何副本，servers 与 circle 保持一致
+	if n := c.circle.Len(); n != c.replicas {
+		t.Fatalf("len(circle) = %d, want %d", n, c.replicas)
+	}
+	if len(c.servers) != c.circle.Len() {
+		t.Fatalf("len(servers) = %d, len(circle) = %d", len(c.servers), c.circle.Len())
+	}
+	for _, key := range c.circle {
+		if c.servers[key] != "192.168.0.1" {
+			t.Fatalf("position %d belongs to %q", key, c.servers[key])
+		}
+	}
+	if !sort.IsSorted(c.circle) {
+		t.Fatal("circle is not sorted")
+	}
+}
+
+func TestWeight(t *testing.T) {
+	c := New(WithHash(md5Hash), WithReplicas(1000), WithWeights(map[string]int{
+		"192.168.0.1": 1,
+		"192.168.0.2": 2,
+	})).(*consistent)
+	if n := c.circle.Len(); n != 3000 {
+		t.Fatalf("len(circle) = %d, want 3000", n)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	statistic := make(map[string]int)
+	for i := 0; i < 100000; i++ {
+		statistic[c.Get(strconv.Itoa(r.Int()))]++
+	}
 	t.Log(statistic)
+	ratio := float64(statistic["192.168.0.2"]) / float64(statistic["192.168.0.1"])
+	if ratio < 1.6 || ratio > 2.4 {
+		t.Fatalf("weight-2 node received %.2fx the keys of weight-1 node, want ~2x", ratio)
+	}
+
+	if !c.AddWeight("192.168.0.3", 3) {
+		t.Fatal("AddWeight returned false for a new node")
+	}
+	if c.AddWeight("192.168.0.4", 0) {
+		t.Fatal("AddWeight returned true for a non-positive weight")
+	}
+	c.Delete("192.168.0.3")
+	c.Delete("192.168.0.2")
+	if n := c.circle.Len(); n != 1000 {
+		t.Fatalf("len(circle) after Delete = %d, want 1000", n)
+	}
+}
+
+func TestHash64NoCollision(t *testing.T) {
+	weights := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		weights[fmt.Sprintf("node-%d", i)] = 1
+	}
+	c := New(WithWeights(weights), WithHash64(func(name string) uint64 {
+		f := fnv.New64a()
+		f.Write([]byte(name))
+		return f.Sum64()
+	})).(*consistent)
+	want := 10000 * c.replicas
+	if n := len(c.servers); n != want {
+		t.Fatalf("len(servers) = %d, want %d", n, want)
+	}
+	if n := c.circle.Len(); n != want {
+		t.Fatalf("len(circle) = %d, want %d", n, want)
+	}
+	for i := 1; i < c.circle.Len(); i++ {
+		if c.circle[i] == c.circle[i-1] {
+			t.Fatalf("duplicate position %d on the ring", c.circle[i])
+		}
+	}
+	if server := c.Get("key"); server == "" {
+		t.Fatal("Get on 64-bit ring returned empty")
+	}
+}
+
+func TestCollisionTieBreak(t *testing.T) {
+	// a#0 和 b#0 的位置相同，其他的 key 的位置为 key 本身的数值
+	positions := map[string]uint32{"a#0": 100, "b#0": 100, "a#1": 200, "b#1": 300}
+	build := func(order ...string) *consistent {
+		c := New(WithHash(func(name string) uint32 {
+			if pos, ok := positions[name]; ok {
+				return pos
+			}
+			if v, err := strconv.Atoi(name); err == nil {
+				return uint32(v)
+			}
+			return fnv32(name)
+		}), WithReplicas(2)).(*consistent)
+		for _, node := range order {
+			c.Add(node)
+		}
+		return c
+	}
+
+	// 无论添加的顺序如何，标识较小的 a 都保留冲突的位置
+	want := build("a", "b")
+	for _, c := range []*consistent{want, build("b", "a")} {
+		if server := c.Get("100"); server != "a" {
+			t.Fatalf("Get(100) = %s, want a", server)
+		}
+		if !reflect.DeepEqual(c.circle, want.circle) || !reflect.DeepEqual(c.servers, want.servers) {
+			t.Fatal("ring depends on the order nodes were added")
+		}
+		if err := c.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		// 重新构建圆环之后结果不变
+		for i := 0; i < 3; i++ {
+			c.SetReplicas(3)
+			c.SetReplicas(2)
+			if server := c.Get("100"); server != "a" || !reflect.DeepEqual(c.circle, want.circle) {
+				t.Fatalf("Get(100) = %s after rebuild, want a", server)
+			}
+		}
+	}
+}
+
+func TestCollisionRestoredOnDelete(t *testing.T) {
+	// a#0 和 b#0 的位置相同，b 的副本重新探测到了其他位置
+	positions := map[string]uint32{"a#0": 100, "b#0": 100, "a#1": 200, "b#1": 300}
+	build := func(nodes ...string) *consistent {
+		c := New(WithHash(func(name string) uint32 {
+			if pos, ok := positions[name]; ok {
+				return pos
+			}
+			return fnv32(name)
+		}), WithReplicas(2)).(*consistent)
+		for _, node := range nodes {
+			c.Add(node)
+		}
+		return c
+	}
+
+	c := build("a", "b")
+	c.Delete("a")
+	if !c.Equal(build("b")) {
+		t.Fatal("ring after Delete differs from the ring built without the node")
+	}
+	if server := c.Get("100"); server != "b" {
+		t.Fatalf("Get(100) = %s, want b", server)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 哈希值只有 128 种，批量删除之后同样与只使用剩余节点构建的圆环一致
+	hash := WithHash(func(name string) uint32 {
+		return md5Hash(name) % 128
+	})
+	ips := []string{"192.168.0.1", "192.168.0.2", "192.168.0.3", "192.168.0.4"}
+	c = New(hash).(*consistent)
+	c.AddBatch(ips...)
+	c.DeleteBatch(ips[0], ips[2])
+	want := New(hash)
+	want.Add(ips[1])
+	want.Add(ips[3])
+	if !c.Equal(want) {
+		t.Fatal("ring after DeleteBatch differs from the ring built without the nodes")
+	}
+}
+
+func TestCollision(t *testing.T) {
+	// 哈希值只有 128 种，添加的副本之间必然会发生冲突
+	c := New(WithHash(func(name string) uint32 {
+		return md5Hash(name) % 128
+	})).(*consistent)
+	ips := []string{"192.168.0.1", "192.168.0.2", "192.168.0.3", "192.168.0.4"}
+	for _, ip := range ips {
+		c.Add(ip)
+	}
+
+	if n := c.circle.Len(); n != len(ips)*c.replicas {
+		t.Fatalf("len(circle) = %d, want %d", n, len(ips)*c.replicas)
+	}
+	for _, ip := range ips {
+		positions := c.nodes[ip].positions
+		if positions.Len() != c.replicas {
+			t.Fatalf("%s has %d positions, want %d", ip, positions.Len(), c.replicas)
+		}
+		for _, pos := range positions {
+			if c.servers[pos] != ip {
+				t.Fatalf("position %d belongs to %s, want %s", pos, c.servers[pos], ip)
+			}
+		}
+	}
+
+	c.Delete("192.168.0.1")
+	if n := c.circle.Len(); n != (len(ips)-1)*c.replicas {
+		t.Fatalf("len(circle) after Delete = %d, want %d", n, (len(ips)-1)*c.replicas)
+	}
+	for _, ip := range ips[1:] {
+		for _, pos := range c.nodes[ip].positions {
+			if c.servers[pos] != ip {
+				t.Fatalf("position %d belongs to %s after Delete, want %s", pos, c.servers[pos], ip)
+			}
+		}
+	}
+}
+
+func TestContainsLen(t *testing.T) {
+	// 通过接口访问所有的实现，jump 和 anchor 的节点只能是从 0 开始的编号
+	hashers := map[string]ConsistentHasher{
+		"ring":        New(),
+		"rendezvous":  NewRendezvous(),
+		"jump":        NewJump(0),
+		"maglev":      NewMaglev(257),
+		"multi-probe": NewMultiProbe(21),
+		"shards":      New(WithShards(4)),
+		"anchor":      NewAnchor(8),
+	}
+	for name, h := range hashers {
+		if h.Len() != 0 || h.Contains("0") {
+			t.Fatalf("%s: empty hasher has Len() = %d", name, h.Len())
+		}
+		for i := 0; i < 3; i++ {
+			h.Add(strconv.Itoa(i))
+		}
+		if h.Len() != 3 || !h.Contains("0") || !h.Contains("2") || h.Contains("3") {
+			t.Fatalf("%s: Len() = %d after adding 3 nodes", name, h.Len())
+		}
+		h.Delete("2")
+		if h.Len() != 2 || h.Contains("2") {
+			t.Fatalf("%s: Len() = %d after Delete", name, h.Len())
+		}
+	}
+}
+
+func TestAddBatch(t *testing.T) {
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	if n := c.AddBatch("192.168.0.1", "192.168.0.2", "192.168.0.3", "192.168.0.3"); n != 2 {
+		t.Fatalf("AddBatch returned %d, want 2", n)
+	}
+	if n := c.circle.Len(); n != 3*c.replicas {
+		t.Fatalf("len(circle) = %d, want %d", n, 3*c.replicas)
+	}
+	if !sort.IsSorted(c.circle) {
+		t.Fatal("circle is not sorted after AddBatch")
+	}
+}
+
+func TestDeleteBatch(t *testing.T) {
+	c := New().(*consistent)
+	want := New().(*consistent)
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("node-%d", i))
+		if i%2 == 1 {
+			want.Add(fmt.Sprintf("node-%d", i))
+		}
+	}
+	slots := []string{"node-100"}
+	for i := 0; i < 100; i += 2 {
+		slots = append(slots, fmt.Sprintf("node-%d", i))
+	}
+	if n := c.DeleteBatch(slots...); n != 50 {
+		t.Fatalf("DeleteBatch returned %d, want 50", n)
+	}
+	if !reflect.DeepEqual(c.circle, want.circle) || !reflect.DeepEqual(c.servers, want.servers) {
+		t.Fatal("circle after DeleteBatch differs from a rebuilt circle")
+	}
+	if c.Get("key") != want.Get("key") {
+		t.Fatal("Get after DeleteBatch differs from a rebuilt ring")
+	}
+	if n := c.DeleteBatch("node-0"); n != 0 {
+		t.Fatalf("DeleteBatch of absent node returned %d, want 0", n)
+	}
+}
+
+func TestDeleteWhere(t *testing.T) {
+	var removed []string
+	c := New(WithOnRemove(func(slot string) {
+		removed = append(removed, slot)
+	})).(*consistent)
+	want := New().(*consistent)
+	for i := 0; i < 10; i++ {
+		c.AddBatch(fmt.Sprintf("192.168.0.%d", i), fmt.Sprintf("192.168.1.%d", i), fmt.Sprintf("10.192.168.1.%d", i))
+		want.AddBatch(fmt.Sprintf("192.168.0.%d", i), fmt.Sprintf("10.192.168.1.%d", i))
+	}
+	n := c.DeleteWhere(func(slot string) bool {
+		return strings.HasPrefix(slot, "192.168.1.")
+	})
+	if n != 10 || len(removed) != 10 || !sort.StringsAreSorted(removed) {
+		t.Fatalf("DeleteWhere returned %d, removed %v, want 10 nodes in order", n, removed)
+	}
+	if !reflect.DeepEqual(c.Members(), want.Members()) {
+		t.Fatalf("Members() = %v, want %v", c.Members(), want.Members())
+	}
+	if !reflect.DeepEqual(c.circle, want.circle) || !reflect.DeepEqual(c.servers, want.servers) {
+		t.Fatal("circle after DeleteWhere differs from a rebuilt circle")
+	}
+	if n := c.DeleteWhere(func(string) bool { return false }); n != 0 {
+		t.Fatalf("DeleteWhere matching nothing returned %d, want 0", n)
+	}
+}
+
+func TestWithInitialNodes(t *testing.T) {
+	slots := []string{"192.168.0.1", "192.168.0.2", "192.168.0.3"}
+	// 节点在其他参数选项之后添加
+	c := New(WithInitialNodes(slots...), WithReplicas(50), WithHash(md5Hash)).(*consistent)
+	want := New(WithReplicas(50), WithHash(md5Hash)).(*consistent)
+	want.AddBatch(slots...)
+	if !reflect.DeepEqual(c.circle, want.circle) || !reflect.DeepEqual(c.servers, want.servers) {
+		t.Fatal("ring built by WithInitialNodes differs from AddBatch")
+	}
+	if !reflect.DeepEqual(c.Members(), want.Members()) {
+		t.Fatalf("Members() = %v, want %v", c.Members(), want.Members())
+	}
+	if c.Get("key") != want.Get("key") {
+		t.Fatal("Get differs from the ring built by AddBatch")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	c := New().(*consistent)
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("node-%d", i))
+	}
+	for i := 0; i < 100; i += 2 {
+		c.Delete(fmt.Sprintf("node-%d", i))
+	}
+
+	// 与重新构建的圆环进行比较
+	want := New().(*consistent)
+	for i := 1; i < 100; i += 2 {
+		want.Add(fmt.Sprintf("node-%d", i))
+	}
+	if !reflect.DeepEqual(c.circle, want.circle) {
+		t.Fatal("circle after Delete differs from a rebuilt circle")
+	}
+	if !reflect.DeepEqual(c.servers, want.servers) {
+		t.Fatal("servers after Delete differs from a rebuilt ring")
+	}
+}
+
+func TestContains(t *testing.T) {
+	c := New()
+	if c.Contains("192.168.0.1") {
+		t.Fatal("Contains returned true before Add")
+	}
+	c.Add("192.168.0.1")
+	if !c.Contains("192.168.0.1") {
+		t.Fatal("Contains returned false after Add")
+	}
+	c.Delete("192.168.0.1")
+	if c.Contains("192.168.0.1") {
+		t.Fatal("Contains returned true after Delete")
+	}
+}
+
+func TestLen(t *testing.T) {
+	c := New().(*consistent)
+	if !c.IsEmpty() || c.Len() != 0 {
+		t.Fatalf("new ring: Len() = %d, IsEmpty() = %v", c.Len(), c.IsEmpty())
+	}
+	c.Add("192.168.0.1")
+	c.Add("192.168.0.2")
+	if c.IsEmpty() || c.Len() != 2 {
+		t.Fatalf("Len() = %d, IsEmpty() = %v, want 2, false", c.Len(), c.IsEmpty())
+	}
+	c.Delete("192.168.0.1")
+	if c.Len() != 1 {
+		t.Fatalf("Len() after Delete = %d, want 1", c.Len())
+	}
+}
+
+func TestReset(t *testing.T) {
+	c := New(WithReplicas(10)).(*consistent)
+	c.Add("192.168.0.1")
+	c.Add("192.168.0.2")
+	c.Reset()
+	if c.Len() != 0 {
+		t.Fatalf("Len() after Reset = %d, want 0", c.Len())
+	}
+	if server := c.Get("key"); server != "" {
+		t.Fatalf("Get after Reset = %q, want empty", server)
+	}
+
+	c.Add("192.168.0.3")
+	if server := c.Get("key"); server != "192.168.0.3" {
+		t.Fatalf("Get after Reset and Add = %q, want 192.168.0.3", server)
+	}
+	if n := c.circle.Len(); n != 10 {
+		t.Fatalf("len(circle) = %d, want 10", n)
+	}
+}
+
+func TestClone(t *testing.T) {
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	c.Add("192.168.0.2")
+
+	clone := c.Clone().(*consistent)
+	clone.Add("192.168.0.3")
+	clone.Delete("192.168.0.1")
+
+	if members := c.Members(); len(members) != 2 || !c.Contains("192.168.0.1") || c.Contains("192.168.0.3") {
+		t.Fatalf("original Members() changed after mutating the clone: %v", members)
+	}
+	if n := c.circle.Len(); n != 2*c.replicas {
+		t.Fatalf("original len(circle) = %d, want %d", n, 2*c.replicas)
+	}
+	if members := clone.Members(); len(members) != 2 || !clone.Contains("192.168.0.3") {
+		t.Fatalf("clone Members() = %v", members)
+	}
+}
+
+func TestVNodeFormatter(t *testing.T) {
+	// 旧的格式 strconv.Itoa(i) + node 下，这两个副本的字符串相同
+	if a, b := vnodeFormat("1key", 1), vnodeFormat("key", 11); a == b {
+		t.Fatalf("vnodeFormat(1key, 1) and vnodeFormat(key, 11) are both %q", a)
+	}
+
+	c := New(WithVNodeFormatter(func(node string, replica int) string {
+		return fmt.Sprintf("%s-%d", node, replica)
+	})).(*consistent)
+	c.Add("192.168.0.1")
+	if pos := c.hash("192.168.0.1-0"); c.servers[pos] != "192.168.0.1" {
+		t.Fatal("custom formatter is not used for virtual nodes")
+	}
+}
+
+func TestVNodeKey(t *testing.T) {
+	// 之前的探测格式 node#probe#replica 下，"a" 的第 1 次探测与节点 "a#1" 的副本相同
+	if a, b := vnodeKey("a", 0, 1), vnodeKey("a#1", 0, 0); a == b {
+		t.Fatalf("vnodeKey(a, 0, 1) and vnodeKey(a#1, 0, 0) are both %q", a)
+	}
+	if vnodeKey("key", 3, 0) != vnodeFormat("key", 3) {
+		t.Fatal("vnodeKey without probe differs from vnodeFormat")
+	}
+
+	// 包含分隔符和数字的节点名称的所有组合都生成不同的字符串
+	nodes := []string{"", "1", "11", "key", "1key", "key1", "a#1", "a#1/2", "a/1", "#", "/", "#1", "1#", "a#", "a#0#1"}
+	seen := make(map[string][3]interface{})
+	for _, node := range nodes {
+		for replica := 0; replica < 12; replica++ {
+			for probe := 0; probe < 4; probe++ {
+				key := vnodeKey(node, replica, probe)
+				if prev, ok := seen[key]; ok {
+					t.Fatalf("vnodeKey(%q, %d, %d) = %q, same as %v", node, replica, probe, key, prev)
+				}
+				seen[key] = [3]interface{}{node, replica, probe}
+			}
+		}
+	}
+}
+
+func TestMembersWithReplicas(t *testing.T) {
+	c := New(WithHash64(xxhash)).(*consistent)
+	c.AddWithReplicas("192.168.1.1", 5)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			c.Add(fmt.Sprintf("192.168.0.%d", i))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		members := c.MembersWithReplicas()
+		for node, replicas := range members {
+			want := c.replicas
+			if node == "192.168.1.1" {
+				want = 5
+			}
+			if replicas != want {
+				t.Fatalf("MembersWithReplicas()[%q] = %d, want %d", node, replicas, want)
+			}
+		}
+	}
+	wg.Wait()
+	if n := len(c.MembersWithReplicas()); n != 101 {
+		t.Fatalf("len(MembersWithReplicas()) = %d, want 101", n)
+	}
+}
+
+func TestSetReplicas(t *testing.T) {
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	c.Add("192.168.0.2")
+	c.AddWeight("192.168.0.3", 2)
+
+	if err := c.SetReplicas(0); err != ErrInvalidReplicas {
+		t.Fatalf("SetReplicas(0) = %v, want ErrInvalidReplicas", err)
+	}
+	if err := c.SetReplicas(50); err != nil {
+		t.Fatalf("SetReplicas(50) = %v", err)
+	}
+	// 权重为 2 的节点拥有 2 倍的副本
+	if n := c.circle.Len(); n != (len(c.nodes)+1)*50 {
+		t.Fatalf("len(circle) = %d, want %d", n, (len(c.nodes)+1)*50)
+	}
+	if n := len(c.servers); n != c.circle.Len() {
+		t.Fatalf("len(servers) = %d, want %d", n, c.circle.Len())
+	}
+	if !sort.IsSorted(c.circle) {
+		t.Fatal("circle is not sorted after SetReplicas")
+	}
+
+	c.Delete("192.168.0.3")
+	if n := c.circle.Len(); n != len(c.nodes)*50 {
+		t.Fatalf("len(circle) = %d, want %d", n, len(c.nodes)*50)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var added, removed []string
+	var c *consistent
+	c = New(WithOnAdd(func(slot string) {
+		// 回调在锁外执行，可以访问圆环
+		if !c.Contains(slot) {
+			t.Errorf("OnAdd(%s) fired before the node was added", slot)
+		}
+		added = append(added, slot)
+	}), WithOnRemove(func(slot string) {
+		removed = append(removed, slot)
+	})).(*consistent)
+
+	c.Add("192.168.0.1")
+	c.Add("192.168.0.1")
+	c.AddWeight("192.168.0.2", 2)
+	c.AddBatch("192.168.0.2", "192.168.0.3", "192.168.0.4")
+	c.Delete("192.168.0.1")
+	c.Delete("192.168.0.1")
+	c.Delete("10.0.0.1")
+	c.Reset()
+
+	if want := []string{"192.168.0.1", "192.168.0.2", "192.168.0.3", "192.168.0.4"}; !reflect.DeepEqual(added, want) {
+		t.Fatalf("OnAdd fired for %v, want %v", added, want)
+	}
+	if want := []string{"192.168.0.1", "192.168.0.2", "192.168.0.3", "192.168.0.4"}; !reflect.DeepEqual(removed, want) {
+		t.Fatalf("OnRemove fired for %v, want %v", removed, want)
+	}
+}
+
+func TestGetWithPos(t *testing.T) {
+	c := New(WithHash(func(name string) uint32 {
+		v, _ := strconv.Atoi(name)
+		return uint32(v)
+	}), WithReplicas(1), WithVNodeFormatter(func(node string, replica int) string {
+		return node
+	})).(*consistent)
+	if server, pos := c.GetWithPos("1"); server != "" || pos != 0 {
+		t.Fatalf("GetWithPos on empty ring = (%q, %d)", server, pos)
+	}
+	c.Add("100")
+	c.Add("200")
+
+	tests := []struct {
+		key    string
+		server string
+		pos    uint64
+	}{
+		{"50", "100", 100},
+		{"100", "100", 100},
+		{"150", "200", 200},
+		// 超过最后一个位置，绕回到第一个位置
+		{"250", "100", 100},
+	}
+	for _, tt := range tests {
+		if server, pos := c.GetWithPos(tt.key); server != tt.server || pos != tt.pos {
+			t.Errorf("GetWithPos(%q) = (%q, %d), want (%q, %d)", tt.key, server, pos, tt.server, tt.pos)
+		}
+	}
+}
+
+func TestSingleReplica(t *testing.T) {
+	// 节点的位置为节点名称的哈希值，key 的位置为 key 本身的数值
+	positions := map[string]uint32{"a": 100, "b": 200, "c": 300}
+	c := New(WithHash(func(name string) uint32 {
+		if pos, ok := positions[name]; ok {
+			return pos
+		}
+		v, _ := strconv.Atoi(name)
+		return uint32(v)
+	}), WithReplicas(1)).(*consistent)
+	c.AddBatch("a", "b", "c")
+	if want := (uints{100, 200, 300}); !reflect.DeepEqual(c.circle, want) {
+		t.Fatalf("circle = %v, want %v", c.circle, want)
+	}
+
+	tests := map[string]string{"50": "a", "100": "a", "150": "b", "250": "c", "350": "a"}
+	for key, want := range tests {
+		if server := c.Get(key); server != want {
+			t.Errorf("Get(%q) = %s, want %s", key, server, want)
+		}
+	}
+	// 删除之后 (100, 200] 属于 c
+	c.Delete("b")
+	tests = map[string]string{"50": "a", "150": "c", "250": "c", "350": "a"}
+	for key, want := range tests {
+		if server := c.Get(key); server != want {
+			t.Errorf("Get(%q) after Delete = %s, want %s", key, server, want)
+		}
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddReturningPositions(t *testing.T) {
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	positions := c.AddReturningPositions("192.168.0.2")
+	if len(positions) != c.replicas {
+		t.Fatalf("AddReturningPositions returned %d positions, want %d", len(positions), c.replicas)
+	}
+	if !sort.SliceIsSorted(positions, func(i, j int) bool { return positions[i] < positions[j] }) {
+		t.Fatal("positions are not sorted")
+	}
+	for _, pos := range positions {
+		if i := c.circle.search(pos); c.circle[i] != pos {
+			t.Fatalf("position %d is not in circle", pos)
+		}
+		if server := c.servers[pos]; server != "192.168.0.2" {
+			t.Fatalf("position %d belongs to %s", pos, server)
+		}
+	}
+	if positions := c.AddReturningPositions("192.168.0.2"); positions != nil {
+		t.Fatalf("duplicate AddReturningPositions = %v, want nil", positions)
+	}
+}
+
+func TestAddWithReplicas(t *testing.T) {
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	if !c.AddWithReplicas("192.168.0.2", 50) {
+		t.Fatal("AddWithReplicas returned false for a new node")
+	}
+	if c.AddWithReplicas("192.168.0.3", 0) {
+		t.Fatal("AddWithReplicas returned true for non-positive replicas")
+	}
+	if n := c.circle.Len(); n != 20+50 {
+		t.Fatalf("len(circle) = %d, want %d", n, 20+50)
+	}
+
+	// 修改全局的副本数量不影响单独指定的副本数量
+	c.SetReplicas(10)
+	if n := c.circle.Len(); n != 10+50 {
+		t.Fatalf("len(circle) after SetReplicas = %d, want %d", n, 10+50)
+	}
+
+	c.Delete("192.168.0.2")
+	if n := c.circle.Len(); n != 10 {
+		t.Fatalf("len(circle) after Delete = %d, want 10", n)
+	}
+	if n := len(c.servers); n != 10 {
+		t.Fatalf("len(servers) after Delete = %d, want 10", n)
+	}
+}
+
+func TestWithReplicasPerNode(t *testing.T) {
+	perNode := map[string]int{"192.168.0.1": 5, "192.168.0.2": 50, "10.0.0.1": 30}
+	c := New(WithReplicasPerNode(perNode), WithInitialNodes("192.168.0.1", "192.168.0.2", "192.168.0.3")).(*consistent)
+	// 未列出的 192.168.0.3 使用默认的 20 个副本
+	if n := c.VirtualNodes(); n != 5+50+20 {
+		t.Fatalf("VirtualNodes() = %d, want %d", n, 5+50+20)
+	}
+	if n := c.AddBatch("10.0.0.1", "10.0.0.2"); n != 2 {
+		t.Fatalf("AddBatch returned %d, want 2", n)
+	}
+	if n := c.VirtualNodes(); n != 5+50+20+30+20 {
+		t.Fatalf("VirtualNodes() after AddBatch = %d, want %d", n, 5+50+20+30+20)
+	}
+
+	c.Delete("192.168.0.2")
+	c.Delete("10.0.0.2")
+	if n := c.VirtualNodes(); n != 5+20+30 {
+		t.Fatalf("VirtualNodes() after Delete = %d, want %d", n, 5+20+30)
+	}
+	if n := len(c.servers); n != 5+20+30 {
+		t.Fatalf("len(servers) after Delete = %d, want %d", n, 5+20+30)
+	}
+}
+
+func TestAddWeightFloat(t *testing.T) {
+	c := New(WithReplicas(40), WithFloatWeights(map[string]float64{"192.168.0.1": 1, "192.168.0.2": 0})).(*consistent)
+	if !c.AddWeightFloat("192.168.0.3", 1.5) {
+		t.Fatal("AddWeightFloat returned false for a new node")
+	}
+	if c.AddWeightFloat("192.168.0.4", -1) || c.AddWeightFloat("192.168.0.4", math.NaN()) || c.AddWeightFloat("192.168.0.3", 2) {
+		t.Fatal("AddWeightFloat accepted an invalid weight or an existing node")
+	}
+	if c.Contains("192.168.0.2") {
+		t.Fatal("WithFloatWeights added a node with zero weight")
+	}
+	if n := len(c.nodes["192.168.0.3"].positions); n != 60 {
+		t.Fatalf("weight 1.5 with base 40 has %d virtual nodes, want 60", n)
+	}
+
+	// 副本数量较多时 key 的分布接近 1.5:1
+	c = New(WithReplicas(400), WithHash(md5Hash)).(*consistent)
+	c.AddWeightFloat("192.168.0.1", 1)
+	c.AddWeightFloat("192.168.0.2", 1.5)
+	dist := c.Distribution(100000)
+	ratio := float64(dist["192.168.0.2"]) / float64(dist["192.168.0.1"])
+	t.Log(dist, ratio)
+	if math.Abs(ratio-1.5) > 0.15 {
+		t.Fatalf("keyspace ratio = %.3f, want 1.5 ± 0.15", ratio)
+	}
+}
+
+func TestDeleteAbsent(t *testing.T) {
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	c.Add("192.168.0.2")
+	circle, snapshot := c.circle, c.load()
+
+	if c.DeleteOK("10.0.0.1") {
+		t.Fatal("DeleteOK returned true for an absent node")
+	}
+	c.Delete("10.0.0.1")
+	// 圆环没有被重新构建
+	if &c.circle[0] != &circle[0] || c.circle.Len() != circle.Len() || c.load() != snapshot {
+		t.Fatal("deleting an absent node rebuilt the circle")
+	}
+
+	if !c.DeleteOK("192.168.0.1") {
+		t.Fatal("DeleteOK returned false for an existing node")
+	}
+	if c.DeleteOK("192.168.0.1") {
+		t.Fatal("DeleteOK returned true for an already deleted node")
+	}
+}
+
+func TestNewReplicas(t *testing.T) {
+	for _, count := range []int{0, -1} {
+		func() {
+			defer func() {
+				if r := recover(); r != ErrInvalidReplicas {
+					t.Fatalf("New(WithReplicas(%d)) panicked with %v, want ErrInvalidReplicas", count, r)
+				}
+			}()
+			New(WithReplicas(count))
+		}()
+	}
+
+	c := New(WithReplicas(5)).(*consistent)
+	c.Add("192.168.0.1")
+	if n := c.circle.Len(); n != 5 {
+		t.Fatalf("len(circle) = %d, want 5", n)
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	c := New().(*consistent)
+	keys := []string{"a", "b", "c"}
+	if got := c.GetMany(keys); !reflect.DeepEqual(got, []string{"", "", ""}) {
+		t.Fatalf("GetMany on empty ring = %v", got)
+	}
+	c.AddBatch("192.168.0.1", "192.168.0.2", "192.168.0.3")
+	got := c.GetMany(keys)
+	for i, key := range keys {
+		if got[i] != c.Get(key) {
+			t.Fatalf("GetMany()[%d] = %s, want %s", i, got[i], c.Get(key))
+		}
+	}
+}
+
+func TestGroupByNode(t *testing.T) {
+	c := New().(*consistent)
+	if groups := c.GroupByNode([]string{"key"}); len(groups) != 0 {
+		t.Fatalf("GroupByNode on empty ring = %v", groups)
+	}
+	c.AddBatch("192.168.0.1", "192.168.0.2", "192.168.0.3")
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = sampleKey(i)
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for node, group := range c.GroupByNode(keys) {
+		for _, key := range group {
+			if seen[key] {
+				t.Fatalf("%q appears in more than one group", key)
+			}
+			seen[key] = true
+			if server := c.Get(key); server != node {
+				t.Fatalf("%q grouped under %s, Get = %s", key, node, server)
+			}
+		}
+	}
+	if len(seen) != len(keys) {
+		t.Fatalf("groups contain %d keys, want %d", len(seen), len(keys))
+	}
+}
+
+func TestVirtualNodes(t *testing.T) {
+	c := New().(*consistent)
+	c.AddBatch("192.168.0.1", "192.168.0.2", "192.168.0.3")
+	if n := c.VirtualNodes(); n != c.Len()*c.replicas {
+		t.Fatalf("VirtualNodes() = %d, want %d", n, c.Len()*c.replicas)
+	}
+
+	// 只有 8 个位置，无法放下所有的副本
+	small := New(WithHash(func(name string) uint32 {
+		return md5Hash(name) % 8
+	})).(*consistent)
+	small.Add("192.168.0.1")
+	if n := small.VirtualNodes(); n != 8 || n >= small.Len()*small.replicas {
+		t.Fatalf("VirtualNodes() = %d, want 8", n)
+	}
+}
+
+func TestRename(t *testing.T) {
+	c := New().(*consistent)
+	c.AddBatch("192.168.0.1", "192.168.0.2", "192.168.0.3")
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = c.Get(key)
+	}
+
+	if c.Rename("10.0.0.1", "host-0") || c.Rename("192.168.0.1", "192.168.0.2") {
+		t.Fatal("Rename with an absent old or an existing new name returned true")
+	}
+	if !c.Rename("192.168.0.1", "host-1") {
+		t.Fatal("Rename returned false")
+	}
+	if c.Contains("192.168.0.1") || !c.Contains("host-1") {
+		t.Fatalf("Members() after Rename = %v", c.Members())
+	}
+
+	check := func() {
+		t.Helper()
+		for key, server := range before {
+			if server == "192.168.0.1" {
+				server = "host-1"
+			}
+			if got := c.Get(key); got != server {
+				t.Fatalf("Get(%q) = %s after Rename, want %s", key, got, server)
+			}
+		}
+	}
+	check()
+
+	// 重新构建圆环以及序列化之后位置保持不变
+	c.SetReplicas(40)
+	c.SetReplicas(20)
+	check()
+	data, _ := c.MarshalBinary()
+	c = New().(*consistent)
+	c.UnmarshalBinary(data)
+	check()
+}
+
+func TestRenameReusedName(t *testing.T) {
+	c := New().(*consistent)
+	c.AddBatch("a", "b")
+	c.Rename("a", "z")
+	// a 仍然是 z 计算位置使用的标识
+	if c.Add("a") || c.Rename("b", "a") {
+		t.Fatal("reused the id of a renamed node as a name")
+	}
+	c.Delete("z")
+	if !c.Add("a") {
+		t.Fatal("Add returned false after the renamed node was deleted")
+	}
+	if !c.Equal(New(WithInitialNodes("a", "b"))) {
+		t.Fatal("ring differs from the ring built with a and b")
+	}
+
+	// 改回原来的名称之后可以再次使用 z
+	c.Rename("a", "z")
+	if !c.Rename("z", "a") || !c.Add("z") {
+		t.Fatal("failed to rename back and reuse the name")
+	}
+}
+
+func TestGetN(t *testing.T) {
+	c := New()
+	ips := []string{"192.168.0.1", "192.168.0.2", "192.168.0.3", "192.168.0.4"}
+	for _, ip := range ips {
+		c.Add(ip)
+	}
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		nodes := c.GetN(key, 3)
+		if len(nodes) != 3 {
+			t.Fatalf("GetN(%q, 3) returned %d nodes", key, len(nodes))
+		}
+		if nodes[0] != c.Get(key) {
+			t.Fatalf("GetN(%q, 3)[0] = %s, want %s", key, nodes[0], c.Get(key))
+		}
+		seen := make(map[string]struct{})
+		for _, node := range nodes {
+			if _, ok := seen[node]; ok {
+				t.Fatalf("GetN(%q, 3) returned duplicate node %s", key, node)
+			}
+			seen[node] = struct{}{}
+		}
+	}
+
+	if nodes := c.GetN("key", 10); len(nodes) != len(ips) {
+		t.Fatalf("GetN with n > nodes returned %d nodes, want %d", len(nodes), len(ips))
+	}
+}
+
+func TestGetNWraparound(t *testing.T) {
+	c := New(WithReplicas(3)).(*consistent)
+	for i := 0; i < 5; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	last := c.circle[c.circle.Len()-1]
+	wrapped := 0
+	for i := 0; i < 10000; i++ {
+		key := sampleKey(i)
+		if c.hash(key) > last {
+			wrapped++
+		}
+		for n := 1; n <= 7; n++ {
+			nodes := c.GetN(key, n)
+			want := n
+			if want > 5 {
+				want = 5
+			}
+			if len(nodes) != want {
+				t.Fatalf("GetN(%q, %d) returned %d nodes, want %d", key, n, len(nodes), want)
+			}
+			seen := make(map[string]bool, len(nodes))
+			for _, node := range nodes {
+				if seen[node] {
+					t.Fatalf("GetN(%q, %d) = %v has duplicates", key, n, nodes)
+				}
+				seen[node] = true
+			}
+		}
+	}
+	// 需要覆盖位于最后一个副本之后、绕回到 circle[0] 的 key
+	if wrapped == 0 {
+		t.Fatal("no sampled key wraps around the ring")
+	}
+	t.Logf("%d keys wrap around", wrapped)
+}
+
+func TestGetNFiltered(t *testing.T) {
+	c := New().(*consistent)
+	// 节点名称的前缀为机架
+	for _, rack := range []string{"rack1", "rack2", "rack3"} {
+		for i := 0; i < 4; i++ {
+			c.Add(fmt.Sprintf("%s/node-%d", rack, i))
+		}
+	}
+	rack := func(node string) string {
+		return strings.SplitN(node, "/", 2)[0]
+	}
+	for i := 0; i < 1000; i++ {
+		key := sampleKey(i)
+		nodes := c.GetNFiltered(key, 3, rack)
+		if len(nodes) != 3 || nodes[0] != c.Get(key) {
+			t.Fatalf("GetNFiltered(%q, 3) = %v, Get = %s", key, nodes, c.Get(key))
+		}
+		racks := make(map[string]bool)
+		for _, node := range nodes {
+			if racks[rack(node)] {
+				t.Fatalf("GetNFiltered(%q, 3) = %v has two nodes in %s", key, nodes, rack(node))
+			}
+			racks[rack(node)] = true
+		}
+		// 只有 3 个机架，最多返回 3 个节点
+		if nodes := c.GetNFiltered(key, 5, rack); len(nodes) != 3 {
+			t.Fatalf("GetNFiltered(%q, 5) = %v, want 3 nodes", key, nodes)
+		}
+	}
+}
+
+func TestGetTwo(t *testing.T) {
+	c := New().(*consistent)
+	if primary, backup := c.GetTwo("key"); primary != "" || backup != "" {
+		t.Fatalf("GetTwo on empty ring = (%q, %q)", primary, backup)
+	}
+	c.Add("192.168.0.1")
+	if primary, backup := c.GetTwo("key"); primary != "192.168.0.1" || backup != "192.168.0.1" {
+		t.Fatalf("GetTwo on single node ring = (%q, %q)", primary, backup)
+	}
+	c.AddBatch("192.168.0.2", "192.168.0.3")
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		primary, backup := c.GetTwo(key)
+		if primary != c.Get(key) || backup == primary || backup == "" {
+			t.Fatalf("GetTwo(%q) = (%q, %q)", key, primary, backup)
+		}
+	}
+}
+
+func TestWalkFrom(t *testing.T) {
+	c := New().(*consistent)
+	c.WalkFrom("key", func(node string) bool {
+		t.Fatal("WalkFrom visited a node on an empty ring")
+		return true
+	})
+	ips := []string{"192.168.0.1", "192.168.0.2", "192.168.0.3", "192.168.0.4"}
+	c.AddBatch(ips...)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		var visited []string
+		c.WalkFrom(key, func(node string) bool {
+			visited = append(visited, node)
+			return true
+		})
+		if !reflect.DeepEqual(visited, c.GetN(key, len(ips))) {
+			t.Fatalf("WalkFrom(%q) visited %v, want %v", key, visited, c.GetN(key, len(ips)))
+		}
+		sorted := append([]string(nil), visited...)
+		sort.Strings(sorted)
+		if !reflect.DeepEqual(sorted, ips) {
+			t.Fatalf("WalkFrom(%q) visited %v, want every node once", key, visited)
+		}
+	}
+
+	count := 0
+	c.WalkFrom("key", func(node string) bool {
+		count++
+		return count < 2
+	})
+	if count != 2 {
+		t.Fatalf("WalkFrom visited %d nodes after early stop, want 2", count)
+	}
+}
+
+func TestClosestNodes(t *testing.T) {
+	positions := map[string]uint32{"a#0": 100, "a#1": 200, "b#0": 300, "b#1": 400}
+	c := New(WithHash(func(name string) uint32 {
+		if pos, ok := positions[name]; ok {
+			return pos
+		}
+		v, _ := strconv.Atoi(name)
+		return uint32(v)
+	}), WithReplicas(2)).(*consistent)
+	if res := c.ClosestNodes("1", 2); res != nil {
+		t.Fatalf("ClosestNodes on empty ring = %v", res)
+	}
+	c.AddBatch("a", "b")
+
+	tests := []struct {
+		key  string
+		n    int
+		want []Position
+	}{
+		// 同一个物理节点的副本不会被跳过
+		{"50", 2, []Position{{"a", 100}, {"a", 200}}},
+		// 超过最后一个位置时绕回到 circle[0]
+		{"350", 3, []Position{{"b", 400}, {"a", 100}, {"a", 200}}},
+		{"450", 10, []Position{{"a", 100}, {"a", 200}, {"b", 300}, {"b", 400}}},
+		{"150", 0, nil},
+	}
+	for _, tt := range tests {
+		if res := c.ClosestNodes(tt.key, tt.n); !reflect.DeepEqual(res, tt.want) {
+			t.Errorf("ClosestNodes(%q, %d) = %v, want %v", tt.key, tt.n, res, tt.want)
+		}
+	}
+}
+
+func TestPredecessor(t *testing.T) {
+	// 副本的位置由表中给出，key 的位置为 key 本身的数值
+	positions := map[string]uint32{"a#0": 100, "a#1": 200, "b#0": 300, "b#1": 400}
+	c := New(WithHash(func(name string) uint32 {
+		if pos, ok := positions[name]; ok {
+			return pos
+		}
+		v, _ := strconv.Atoi(name)
+		return uint32(v)
+	}), WithReplicas(2)).(*consistent)
+	if server := c.Predecessor("1", false); server != "" {
+		t.Fatalf("Predecessor on empty ring = %q", server)
+	}
+	c.AddBatch("a", "b")
+
+	tests := []struct {
+		key         string
+		successor   string
+		predecessor string
+		distinct    string
+	}{
+		// 位于 circle[0] 之前，前驱绕回到最后一个副本
+		{"50", "a", "b", "b"},
+		{"150", "a", "a", "b"},
+		{"250", "b", "a", "a"},
+		{"350", "b", "b", "a"},
+		// 位于最后一个副本之后，后继绕回到 circle[0]
+		{"450", "a", "b", "b"},
+	}
+	for _, tt := range tests {
+		if server := c.Successor(tt.key); server != tt.successor {
+			t.Errorf("Successor(%q) = %q, want %q", tt.key, server, tt.successor)
+		}
+		if server := c.Predecessor(tt.key, false); server != tt.predecessor {
+			t.Errorf("Predecessor(%q, false) = %q, want %q", tt.key, server, tt.predecessor)
+		}
+		if server := c.Predecessor(tt.key, true); server != tt.distinct {
+			t.Errorf("Predecessor(%q, true) = %q, want %q", tt.key, server, tt.distinct)
+		}
+	}
+
+	single := New(WithReplicas(3)).(*consistent)
+	single.Add("192.168.0.1")
+	if server := single.Predecessor("key", true); server != "192.168.0.1" {
+		t.Fatalf("Predecessor on single node ring = %q", server)
+	}
 }
 
 func BenchmarkConsistentHash(b *testing.B) {
@@ -37,10 +1217,444 @@ func BenchmarkConsistentHash(b *testing.B) {
 			c.Get(fmt.Sprintf("key-%d", i))
 		}
 	})
-	
+
 	b.Run("Delete", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
 			c.Delete(fmt.Sprintf("nodes-%d", i))
 		}
 	})
 }
+
+func TestSearch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 50; n++ {
+		u := make(uints, n)
+		for i := range u {
+			u[i] = uint64(r.Intn(1000)) * 2
+		}
+		sort.Sort(u)
+		// 包括重复的元素、不存在的元素以及超过最后一个元素的 key
+		for key := uint64(0); key < 2002; key++ {
+			want := sort.Search(len(u), func(i int) bool { return u[i] >= key })
+			if got := u.lowerBound(key); got != want {
+				t.Fatalf("lowerBound(%d) = %d, want %d", key, got, want)
+			}
+			if want == n {
+				want = 0
+			}
+			if got := u.search(key); got != want {
+				t.Fatalf("search(%d) = %d, want %d", key, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	c := New()
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("nodes-%d", i))
+	}
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = sampleKey(i)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { c.Get(keys[0]) }); allocs != 0 {
+		b.Fatalf("Get allocates %v times per call, want 0", allocs)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Get(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkAddBatch(b *testing.B) {
+	slots := make([]string, 1000)
+	for i := range slots {
+		slots[i] = fmt.Sprintf("nodes-%d", i)
+	}
+
+	b.Run("Add", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c := New()
+			for _, slot := range slots {
+				c.Add(slot)
+			}
+		}
+	})
+
+	b.Run("AddBatch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c := New().(*consistent)
+			c.AddBatch(slots...)
+		}
+	})
+}
+
+func BenchmarkLazySort(b *testing.B) {
+	slots := make([]string, 1000)
+	for i := range slots {
+		slots[i] = fmt.Sprintf("nodes-%d", i)
+	}
+	for name, options := range map[string][]Option{
+		"Eager": nil,
+		"Lazy":  {WithLazySort()},
+	} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				c := New(options...)
+				for _, slot := range slots {
+					c.Add(slot)
+				}
+				c.Get("key")
+			}
+		})
+	}
+}
+
+func BenchmarkDelete(b *testing.B) {
+	// 500 个节点，共 10000 个副本，包括发布快照时复制整个圆环的开销
+	c := New().(*consistent)
+	for i := 0; i < 500; i++ {
+		c.Add(fmt.Sprintf("nodes-%d", i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Delete("nodes-0")
+		b.StopTimer()
+		c.Add("nodes-0")
+		b.StartTimer()
+	}
+}
+
+func TestRemoveAllocs(t *testing.T) {
+	// 记录下标的切片来自 indicesPool，从圆环中删除副本时不需要分配内存
+	c := New().(*consistent)
+	for i := 0; i < 50; i++ {
+		c.Add(fmt.Sprintf("nodes-%d", i))
+	}
+	circle := append(uints(nil), c.circle...)
+	positions := c.nodes["nodes-0"].positions
+	allocs := testing.AllocsPerRun(100, func() {
+		c.remove(positions)
+		// 恢复删除之前的圆环
+		c.circle = c.circle[:len(circle)]
+		copy(c.circle, circle)
+	})
+	if allocs != 0 {
+		t.Fatalf("remove allocates %v times per run, want 0", allocs)
+	}
+}
+
+func BenchmarkDeleteBatch(b *testing.B) {
+	// 从 500 个节点中删除 50 个
+	c := New().(*consistent)
+	for i := 0; i < 500; i++ {
+		c.Add(fmt.Sprintf("nodes-%d", i))
+	}
+	slots := make([]string, 50)
+	for i := range slots {
+		slots[i] = fmt.Sprintf("nodes-%d", i*10)
+	}
+
+	b.Run("Delete", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			nc := c.clone()
+			b.StartTimer()
+			for _, slot := range slots {
+				nc.Delete(slot)
+			}
+		}
+	})
+
+	b.Run("DeleteBatch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			nc := c.clone()
+			b.StartTimer()
+			nc.DeleteBatch(slots...)
+		}
+	})
+}
+
+func BenchmarkGetConcurrent(b *testing.B) {
+	c := New().(*consistent)
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("nodes-%d", i))
+	}
+	// 后台持续修改圆环
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			c.Add("writer")
+			c.Delete("writer")
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	// 加读锁读取圆环，即使用快照之前的方式
+	b.Run("RWMutex", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				c.RLock()
+				_ = c.servers[c.circle[c.circle.search(c.hash("key"))]]
+				c.RUnlock()
+			}
+		})
+	})
+
+	b.Run("Snapshot", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				c.Get("key")
+			}
+		})
+	})
+}
+
+func BenchmarkGetMany(b *testing.B) {
+	c := New().(*consistent)
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("nodes-%d", i))
+	}
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	b.Run("Get", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, key := range keys {
+				c.Get(key)
+			}
+		}
+	})
+
+	b.Run("GetMany", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c.GetMany(keys)
+		}
+	})
+
+	b.Run("GetManyInto", func(b *testing.B) {
+		out := make([]string, len(keys))
+		if allocs := testing.AllocsPerRun(10, func() { c.GetManyInto(keys, out) }); allocs != 0 {
+			b.Fatalf("GetManyInto allocates %v times per call, want 0", allocs)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.GetManyInto(keys, out)
+		}
+	})
+}
+
+func TestGetManyInto(t *testing.T) {
+	c := New().(*consistent)
+	keys := []string{"key-0", "key-1", "key-2", "key-3"}
+	out := []string{"x", "x", "x"}
+	// 没有任何节点时写入空字符串
+	if n := c.GetManyInto(keys, out); n != 3 || out[0] != "" || out[2] != "" {
+		t.Fatalf("GetManyInto on empty ring = %d, %q", n, out)
+	}
+	for i := 0; i < 5; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	want := c.GetMany(keys)
+	if n := c.GetManyInto(keys, out); n != 3 || !reflect.DeepEqual(out, want[:3]) {
+		t.Fatalf("GetManyInto with short out = %d, %v, want %v", n, out, want[:3])
+	}
+	out = make([]string, 6)
+	out[5] = "x"
+	if n := c.GetManyInto(keys, out); n != 4 || !reflect.DeepEqual(out[:4], want) || out[4] != "" || out[5] != "x" {
+		t.Fatalf("GetManyInto with long out = %d, %q", n, out)
+	}
+}
+
+func TestClose(t *testing.T) {
+	for name, h := range map[string]ConsistentHasher{
+		"consistent": New(),
+		"rendezvous": NewRendezvous(),
+		"jump":       NewJump(4),
+		"maglev":     NewMaglev(13),
+		"multiprobe": NewMultiProbe(3),
+		"shards":     New(WithShards(4)),
+		"anchor":     NewAnchor(4),
+	} {
+		if err := h.Close(); err != nil {
+			t.Fatalf("%s: Close() = %v", name, err)
+		}
+		if err := h.Close(); err != nil {
+			t.Fatalf("%s: second Close() = %v", name, err)
+		}
+	}
+
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	c.Close()
+	// Close 之后不再产生事件，也不会向已经关闭的通道发送事件
+	c.Add("192.168.0.2")
+	c.Delete("192.168.0.1")
+	var events []Event
+	for e := range c.Events() {
+		events = append(events, e)
+	}
+	if want := []Event{{Type: Added, Node: "192.168.0.1"}}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	c := New(WithHash(func(name string) uint32 {
+		// 每个节点只有一个副本，a, b, c, d 依次排列在圆环上
+		positions := map[string]uint32{"a#0": 100, "b#0": 200, "c#0": 300, "d#0": 400}
+		if pos, ok := positions[name]; ok {
+			return pos
+		}
+		pos, _ := strconv.Atoi(name)
+		return uint32(pos)
+	}), WithReplicas(1), WithVNodeFormatter(vnodeFormat)).(*consistent)
+	check := func(key, prev, owner, next string) {
+		t.Helper()
+		if p, o, n := c.Neighbors(key); p != prev || o != owner || n != next {
+			t.Fatalf("Neighbors(%s) = %q, %q, %q, want %q, %q, %q", key, p, o, n, prev, owner, next)
+		}
+	}
+	check("150", "", "", "")
+	c.Add("a")
+	check("150", "", "a", "")
+	c.Add("b")
+	check("150", "a", "b", "a")
+	check("50", "b", "a", "b")
+	c.AddBatch("c", "d")
+	check("150", "a", "b", "c")
+	// 超过最后一个位置时绕回
+	check("450", "d", "a", "b")
+	check("100", "d", "a", "b")
+	check("350", "c", "d", "a")
+
+	c = New().(*consistent)
+	for i := 0; i < 5; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		key := sampleKey(i)
+		prev, owner, next := c.Neighbors(key)
+		if nodes := c.GetN(key, 2); owner != nodes[0] || next != nodes[1] {
+			t.Fatalf("Neighbors(%q) = %s, %s, %s, GetN = %v", key, prev, owner, next, nodes)
+		}
+		if prev == owner || prev == next || prev == "" {
+			t.Fatalf("Neighbors(%q) = %q, %q, %q are not distinct", key, prev, owner, next)
+		}
+	}
+}
+
+func TestLazySort(t *testing.T) {
+	eager, lazy := New().(*consistent), New(WithLazySort()).(*consistent)
+	for i := 0; i < 20; i++ {
+		eager.Add(fmt.Sprintf("192.168.0.%d", i))
+		lazy.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	if !lazy.dirty.Load() {
+		t.Fatal("Add sorted the lazy ring eagerly")
+	}
+	lazy.Get("key")
+	if lazy.dirty.Load() {
+		t.Fatal("the first read after Add did not sort the lazy ring")
+	}
+	if err := lazy.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	lazy.AddBatch("10.0.0.1", "10.0.0.2")
+	eager.AddBatch("10.0.0.1", "10.0.0.2")
+	// 删除依赖排序之后的圆环
+	lazy.Delete("192.168.0.3")
+	eager.Delete("192.168.0.3")
+	lazy.AddWeight("10.0.0.3", 3)
+	eager.AddWeight("10.0.0.3", 3)
+	if !lazy.Equal(eager) {
+		t.Fatal("lazy ring differs from the eager ring")
+	}
+	for i := 0; i < 1000; i++ {
+		if key := sampleKey(i); lazy.Get(key) != eager.Get(key) {
+			t.Fatalf("Get(%q) = %s on lazy ring, want %s", key, lazy.Get(key), eager.Get(key))
+		}
+	}
+
+	// 读操作在并发的 Add 中触发排序
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				lazy.Add(fmt.Sprintf("172.16.%d.%d", i, j))
+				lazy.Get(sampleKey(j))
+				lazy.LoadFactor("192.168.0.1")
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := lazy.Validate(); err != nil {
+		t.Fatalf("Validate after concurrent Add: %v", err)
+	}
+}
+
+func TestGetReplica(t *testing.T) {
+	if node, index := New().(*consistent).GetReplica("key"); node != "" || index != -1 {
+		t.Fatalf("GetReplica on empty ring = %q, %d", node, index)
+	}
+	c := New().(*consistent)
+	for i := 0; i < 5; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		key := sampleKey(i)
+		node, index := c.GetReplica(key)
+		if node != c.Get(key) || index < 0 || index >= c.replicas {
+			t.Fatalf("GetReplica(%q) = %s, %d, Get = %s", key, node, index, c.Get(key))
+		}
+		if pos := c.ClosestNodes(key, 1)[0].Pos; c.hashKey(node, index) != pos {
+			t.Fatalf("GetReplica(%q) = %s, %d, but the key lands on %d", key, node, index, pos)
+		}
+	}
+
+	// b 的第 1 个副本以及所有的探测位置都与 a 冲突而被丢弃，第 2 个副本仍然返回原来的编号
+	positions := map[string]uint32{"a#0": 100, "b#0": 200, "b#2": 300, "a#1": 400, "a#2": 500}
+	c = New(WithHash(func(name string) uint32 {
+		if pos, ok := positions[name]; ok {
+			return pos
+		}
+		if strings.HasPrefix(name, "b#1") {
+			return 100
+		}
+		v, _ := strconv.Atoi(name)
+		return uint32(v)
+	}), WithReplicas(3)).(*consistent)
+	c.AddBatch("a", "b")
+	if n := len(c.nodes["b"].positions); n != 2 {
+		t.Fatalf("b has %d positions, want 2", n)
+	}
+	for key, want := range map[string]struct {
+		node  string
+		index int
+	}{"50": {"a", 0}, "150": {"b", 0}, "250": {"b", 2}, "350": {"a", 1}, "450": {"a", 2}} {
+		if node, index := c.GetReplica(key); node != want.node || index != want.index {
+			t.Fatalf("GetReplica(%s) = %s, %d, want %s, %d", key, node, index, want.node, want.index)
+		}
+	}
+	if node, index := c.Clone().(*consistent).GetReplica("250"); node != "b" || index != 2 {
+		t.Fatalf("GetReplica(250) on clone = %s, %d, want b, 2", node, index)
+	}
+}
@@ -0,0 +1,317 @@
+package consistent
+
+import (
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+// 二进制编码的版本号
+const binaryVersion = 2
+
+// ErrInvalidData 反序列化的数据格式不正确
+var ErrInvalidData = errors.New("consistent: invalid encoded data")
+
+// 反序列化时单个节点最多允许的副本数量，避免损坏的数据导致分配大量的内存或者整数溢出
+const maxDecodedReplicas = 1 << 20
+
+// MarshalBinary 实现 encoding.BinaryMarshaler 接口
+// 只序列化副本数量以及节点和对应的权重、单独指定的副本数量、Rename 之前的标识，圆环在反序列化时重新计算，
+// 哈希函数不会被序列化，反序列化时必须使用相同的哈希函数，否则节点的分布会不一致
+func (c *consistent) MarshalBinary() ([]byte, error) {
+	c.RLock()
+	defer c.RUnlock()
+	names := c.sortedMembers()
+
+	buf := make([]byte, 0, 16+len(names)*16)
+	buf = append(buf, binaryVersion)
+	buf = appendUvarint(buf, uint64(c.replicas))
+	buf = appendUvarint(buf, uint64(len(names)))
+	for _, node := range names {
+		buf = appendUvarint(buf, uint64(len(node)))
+		buf = append(buf, node...)
+		buf = appendUvarint(buf, uint64(c.nodes[node].weight))
+		buf = appendUvarint(buf, uint64(c.nodes[node].replicas))
+		buf = appendUvarint(buf, uint64(len(c.nodes[node].id)))
+		buf = append(buf, c.nodes[node].id...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler 接口
+// 使用数据中的副本数量和节点替换当前的节点，并且重新构建圆环，
+// 应该在通过 New 使用相同的哈希函数创建的实例上调用
+func (c *consistent) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != binaryVersion {
+		return ErrInvalidData
+	}
+	data = data[1:]
+	next := func() (uint64, bool) {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return 0, false
+		}
+		data = data[n:]
+		return v, true
+	}
+
+	replicas, ok := next()
+	if !ok || replicas == 0 || replicas > maxDecodedReplicas {
+		return ErrInvalidData
+	}
+	count, ok := next()
+	if !ok {
+		return ErrInvalidData
+	}
+	str := func() (string, bool) {
+		size, ok := next()
+		if !ok || size > uint64(len(data)) {
+			return "", false
+		}
+		s := string(data[:size])
+		data = data[size:]
+		return s, true
+	}
+	members := make(map[string]*member)
+	for i := uint64(0); i < count; i++ {
+		node, ok := str()
+		if !ok {
+			return ErrInvalidData
+		}
+		weight, ok := next()
+		if !ok || weight == 0 {
+			return ErrInvalidData
+		}
+		nodeReplicas, ok := next()
+		if !ok {
+			return ErrInvalidData
+		}
+		id, ok := str()
+		if !ok || !validReplicas(replicas, weight, nodeReplicas) {
+			return ErrInvalidData
+		}
+		members[node] = &member{weight: int(weight), replicas: int(nodeReplicas), id: id}
+	}
+	if len(data) != 0 {
+		return ErrInvalidData
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	c.restore(int(replicas), members)
+	return nil
+}
+
+// Save 将圆环以二进制编码写入到 w 中
+func (c *consistent) Save(w io.Writer) error {
+	data, err := c.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Load 从 r 中读取 Save 写入的数据并重新构建圆环
+// 哈希函数不会被保存，需要通过 options 传入与保存时相同的哈希函数，WithReadOnly 在读取完成之后生效，WithShards 会被忽略
+func Load(r io.Reader, options ...Option) (ConsistentHasher, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	c := New(append(options[:len(options):len(options)], WithShards(1))...).(*consistent)
+	// WithReadOnly 在读取完成之后才生效
+	readOnly := c.readOnly
+	c.readOnly = false
+	if err := c.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if readOnly {
+		c.Freeze()
+	}
+	return c, nil
+}
+
+func init() {
+	// 注册具体的类型，使得 New 返回的接口可以作为 interface 类型的字段通过 gob 传输
+	gob.Register(&consistent{})
+}
+
+// GobEncode 实现 gob.GobEncoder 接口，编码格式与 MarshalBinary 一致
+// 哈希函数以及副本格式等参数选项不会被编码，解码的一端必须使用相同的哈希函数，
+// 否则构建出来的圆环不同，同一个 key 会被分配到不同的节点
+func (c *consistent) GobEncode() ([]byte, error) {
+	return c.MarshalBinary()
+}
+
+// GobDecode 实现 gob.GobDecoder 接口，解码之后重新构建圆环
+// 解码到零值的实例时使用默认的哈希函数
+func (c *consistent) GobDecode(data []byte) error {
+	return c.UnmarshalBinary(data)
+}
+
+// jsonRing 为 JSON 序列化的格式
+type jsonRing struct {
+	Replicas int      `json:"replicas"`
+	Nodes    []string `json:"nodes"`
+	// 权重不为 1 的节点的权重
+	Weights map[string]int `json:"weights,omitempty"`
+	// 单独指定了副本数量的节点的副本数量
+	NodeReplicas map[string]int `json:"node_replicas,omitempty"`
+	// Rename 之后的节点计算副本位置时使用的标识
+	IDs map[string]string `json:"ids,omitempty"`
+}
+
+// MarshalJSON 实现 json.Marshaler 接口
+// 格式为 {"replicas":20,"nodes":["192.168.0.1",...],"weights":{"192.168.0.2":2}}，
+// 节点按照名称排序，weights 只包含权重不为 1 的节点，
+// 通过 AddWithReplicas 添加的节点的副本数量保存在 node_replicas 中，
+// Rename 之后的节点原来的标识保存在 ids 中
+func (c *consistent) MarshalJSON() ([]byte, error) {
+	c.RLock()
+	defer c.RUnlock()
+	r := jsonRing{
+		Replicas: c.replicas,
+		Nodes:    c.sortedMembers(),
+	}
+	for _, node := range r.Nodes {
+		if weight := c.nodes[node].weight; weight != 1 {
+			if r.Weights == nil {
+				r.Weights = make(map[string]int)
+			}
+			r.Weights[node] = weight
+		}
+		if replicas := c.nodes[node].replicas; replicas > 0 {
+			if r.NodeReplicas == nil {
+				r.NodeReplicas = make(map[string]int)
+			}
+			r.NodeReplicas[node] = replicas
+		}
+		if id := c.nodes[node].id; id != "" {
+			if r.IDs == nil {
+				r.IDs = make(map[string]string)
+			}
+			r.IDs[node] = id
+		}
+	}
+	return json.Marshal(r)
+}
+
+// UnmarshalJSON 实现 json.Unmarshaler 接口
+// 与 UnmarshalBinary 一致，哈希函数不会被序列化，需要在使用相同哈希函数的实例上调用
+func (c *consistent) UnmarshalJSON(data []byte) error {
+	var r jsonRing
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+	if r.Replicas <= 0 {
+		return ErrInvalidReplicas
+	}
+	if r.Replicas > maxDecodedReplicas {
+		return ErrInvalidData
+	}
+	members := make(map[string]*member, len(r.Nodes))
+	for _, node := range r.Nodes {
+		weight := 1
+		if w, ok := r.Weights[node]; ok {
+			weight = w
+		}
+		if weight <= 0 || r.NodeReplicas[node] < 0 {
+			return ErrInvalidData
+		}
+		if !validReplicas(uint64(r.Replicas), uint64(weight), uint64(r.NodeReplicas[node])) {
+			return ErrInvalidData
+		}
+		members[node] = &member{weight: weight, replicas: r.NodeReplicas[node], id: r.IDs[node]}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	c.restore(r.Replicas, members)
+	return nil
+}
+
+// restore 使用给定的副本数量和节点重新构建圆环
+func (c *consistent) restore(replicas int, members map[string]*member) {
+	if c.hash == nil {
+		// 零值的实例，使用默认的配置
+		d := config()
+		c.hash, c.maxPos, c.format = d.hash, d.maxPos, d.format
+	}
+	c.replicas = replicas
+	c.nodes = members
+	c.loads = make(map[string]int)
+	c.assigned = make(map[string]string)
+	c.rebuild()
+}
+
+// validReplicas 检查反序列化得到的节点副本数量没有超过 maxDecodedReplicas
+func validReplicas(replicas, weight, nodeReplicas uint64) bool {
+	if nodeReplicas > 0 {
+		return nodeReplicas <= maxDecodedReplicas
+	}
+	// replicas 已经不超过 maxDecodedReplicas，先检查 weight 保证乘积不会溢出
+	return weight <= maxDecodedReplicas && weight*replicas <= maxDecodedReplicas
+}
+
+// appendUvarint 将 v 以 varint 编码追加到 buf 中
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+// Equal 判断两个圆环是否等价：副本数量、节点集合以及圆环上每个位置所属的节点都相同，
+// 哈希函数无法比较，所以只比较其结果，主要用于检查序列化之后恢复的圆环是否与原来的一致，
+// other 不是通过 New 创建的实例时返回 false
+func (c *consistent) Equal(other ConsistentHasher) bool {
+	o, ok := other.(*consistent)
+	if !ok {
+		return false
+	}
+	if o == c {
+		return true
+	}
+	replicas, nodes, r := c.state()
+	otherReplicas, otherNodes, or := o.state()
+	if replicas != otherReplicas || len(nodes) != len(otherNodes) || r.circle.Len() != or.circle.Len() {
+		return false
+	}
+	for i, node := range nodes {
+		if otherNodes[i] != node {
+			return false
+		}
+	}
+	for i, key := range r.circle {
+		if or.circle[i] != key || or.servers[key] != r.servers[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// state 获取副本数量、排序之后的所有节点以及对应的圆环快照
+func (c *consistent) state() (int, []string, *ring) {
+	c.rlock()
+	defer c.RUnlock()
+	return c.replicas, c.sortedMembers(), c.load()
+}
+
+// sortedMembers 获取按照名称排序之后的所有节点
+func (c *consistent) sortedMembers() []string {
+	names := make([]string, 0, len(c.nodes))
+	for node := range c.nodes {
+		names = append(names, node)
+	}
+	sort.Strings(names)
+	return names
+}
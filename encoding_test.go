@@ -0,0 +1,238 @@
+package consistent
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	c := New(WithReplicas(30)).(*consistent)
+	for i := 0; i < 10; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	c.AddWeight("192.168.1.1", 3)
+	c.AddWithReplicas("192.168.1.2", 7)
+
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	restored := New().(*consistent)
+	restored.Add("10.0.0.1")
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	if restored.replicas != 30 {
+		t.Fatalf("replicas = %d, want 30", restored.replicas)
+	}
+	if restored.Contains("10.0.0.1") || restored.Len() != c.Len() {
+		t.Fatalf("Members() = %v, want %v", restored.Members(), c.Members())
+	}
+	if restored.nodes["192.168.1.1"].weight != 3 {
+		t.Fatalf("weight = %d, want 3", restored.nodes["192.168.1.1"].weight)
+	}
+	if restored.nodes["192.168.1.2"].replicas != 7 {
+		t.Fatalf("node replicas = %d, want 7", restored.nodes["192.168.1.2"].replicas)
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if c.Get(key) != restored.Get(key) {
+			t.Fatalf("Get(%q) = %s after round trip, want %s", key, restored.Get(key), c.Get(key))
+		}
+	}
+
+	// 零值的实例使用默认的配置
+	var zero consistent
+	if err := zero.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary into zero value: %v", err)
+	}
+	if zero.Get("key") != c.Get("key") {
+		t.Fatal("zero value instance assigns keys differently")
+	}
+}
+
+func TestUnmarshalBinaryInvalid(t *testing.T) {
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	data, _ := c.MarshalBinary()
+	for _, bad := range [][]byte{nil, {0}, data[:len(data)-1], append(data, 0)} {
+		if err := c.UnmarshalBinary(bad); err != ErrInvalidData {
+			t.Fatalf("UnmarshalBinary(%v) = %v, want ErrInvalidData", bad, err)
+		}
+	}
+	if !c.Contains("192.168.0.1") {
+		t.Fatal("failed UnmarshalBinary modified the ring")
+	}
+
+	// 副本数量过大或者 weight * replicas 溢出
+	encode := func(replicas, weight, nodeReplicas uint64) []byte {
+		buf := []byte{binaryVersion}
+		buf = appendUvarint(buf, replicas)
+		buf = appendUvarint(buf, 1)
+		buf = appendUvarint(buf, 1)
+		buf = append(buf, 'a')
+		buf = appendUvarint(buf, weight)
+		buf = appendUvarint(buf, nodeReplicas)
+		return appendUvarint(buf, 0)
+	}
+	for _, bad := range [][]byte{
+		encode(1<<63, 1, 0),
+		encode(160, 1<<40, 0),
+		encode(4, 1<<62, 0),
+		encode(160, 1, 1<<62),
+	} {
+		if err := c.UnmarshalBinary(bad); err != ErrInvalidData {
+			t.Fatalf("UnmarshalBinary(%v) = %v, want ErrInvalidData", bad, err)
+		}
+	}
+	if err := c.UnmarshalBinary(encode(10, 2, 0)); err != nil || c.nodes["a"].positions.Len() != 20 {
+		t.Fatalf("UnmarshalBinary = %v, want a with 20 replicas", err)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	c.Add("192.168.0.2")
+	c.AddWeight("192.168.0.3", 2)
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	want := `{"replicas":20,"nodes":["192.168.0.1","192.168.0.2","192.168.0.3"],"weights":{"192.168.0.3":2}}`
+	if string(data) != want {
+		t.Fatalf("json.Marshal = %s, want %s", data, want)
+	}
+
+	restored := New().(*consistent)
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if c.Get(key) != restored.Get(key) {
+			t.Fatalf("Get(%q) = %s after round trip, want %s", key, restored.Get(key), c.Get(key))
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"replicas":0,"nodes":[]}`), restored); err != ErrInvalidReplicas {
+		t.Fatalf("json.Unmarshal with zero replicas = %v, want ErrInvalidReplicas", err)
+	}
+	// 副本数量过大或者 weight * replicas 溢出
+	for _, bad := range []string{
+		`{"replicas":4611686018427387904,"nodes":["a"]}`,
+		`{"replicas":160,"nodes":["a"],"weights":{"a":1099511627776}}`,
+		`{"replicas":4,"nodes":["a"],"weights":{"a":4611686018427387904}}`,
+		`{"replicas":1,"nodes":["a"],"node_replicas":{"a":1099511627776}}`,
+	} {
+		if err := json.Unmarshal([]byte(bad), restored); err != ErrInvalidData {
+			t.Fatalf("json.Unmarshal(%s) = %v, want ErrInvalidData", bad, err)
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	c := New(WithMurmur3()).(*consistent)
+	for i := 0; i < 10; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	restored, err := Load(&buf, WithMurmur3())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if c.Get(key) != restored.Get(key) {
+			t.Fatalf("Get(%q) = %s after Load, want %s", key, restored.Get(key), c.Get(key))
+		}
+	}
+
+	if _, err := Load(bytes.NewReader([]byte("invalid"))); err != ErrInvalidData {
+		t.Fatalf("Load with invalid data = %v, want ErrInvalidData", err)
+	}
+
+	// WithShards 被忽略，不会因为类型断言失败而 panic
+	buf.Reset()
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := Load(&buf, WithMurmur3(), WithShards(2)); err != nil {
+		t.Fatalf("Load with WithShards: %v", err)
+	}
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	c := New(WithReplicas(30))
+	for i := 0; i < 10; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	c.(*consistent).AddWeight("192.168.1.1", 3)
+
+	// 通过 interface 类型的字段传输
+	type state struct {
+		Ring ConsistentHasher
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(state{Ring: c}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var restored state
+	if err := gob.NewDecoder(&buf).Decode(&restored); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	r, ok := restored.Ring.(*consistent)
+	if !ok {
+		t.Fatalf("decoded %T, want *consistent", restored.Ring)
+	}
+	if r.replicas != 30 || r.nodes["192.168.1.1"].weight != 3 {
+		t.Fatalf("decoded replicas = %d, weight = %d", r.replicas, r.nodes["192.168.1.1"].weight)
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if r.Get(key) != c.Get(key) {
+			t.Fatalf("Get(%q) = %s after gob round trip, want %s", key, r.Get(key), c.Get(key))
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	c := New(WithReplicas(30)).(*consistent)
+	for i := 0; i < 10; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	c.AddWeight("192.168.1.1", 3)
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	restored := New().(*consistent)
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !c.Equal(restored) || !restored.Equal(c) || !c.Equal(c) {
+		t.Fatal("round-tripped ring is not Equal to the original")
+	}
+
+	restored.Add("192.168.1.2")
+	if c.Equal(restored) {
+		t.Fatal("ring with an extra node is Equal to the original")
+	}
+	restored.Delete("192.168.1.2")
+	restored.SetReplicas(20)
+	if c.Equal(restored) {
+		t.Fatal("ring with different replicas is Equal to the original")
+	}
+	if c.Equal(NewRendezvous()) {
+		t.Fatal("ring is Equal to a rendezvous hasher")
+	}
+}
@@ -0,0 +1,62 @@
+package consistent
+
+// EventType 节点变化的类型
+type EventType int
+
+const (
+	// Added 添加了节点
+	Added EventType = iota
+	// Removed 删除了节点
+	Removed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Added:
+		return "Added"
+	case Removed:
+		return "Removed"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event 节点变化的事件
+type Event struct {
+	Type EventType
+	Node string
+}
+
+// 事件通道的缓冲区大小
+const eventBuffer = 64
+
+// Events 获取节点变化的事件通道
+// 每一次实际发生的节点添加和删除都会产生一个事件，通道的缓冲区大小为 64，
+// 发送事件时不会阻塞，缓冲区满了之后新的事件会被丢弃，因此消费者需要及时读取，
+// 不会启动额外的 goroutine，没有人读取时也不会造成泄露，Close 之后通道会被关闭
+func (c *consistent) Events() <-chan Event {
+	return c.events
+}
+
+// emit 发送事件，缓冲区满了或者事件通道已经关闭则丢弃
+func (c *consistent) emit(e Event) {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.events <- e:
+	default:
+	}
+}
+
+// closeEvents 关闭事件通道，消费者读取完缓冲区中的事件之后退出
+func (c *consistent) closeEvents() {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
+	c.closed = true
+	if c.events != nil {
+		close(c.events)
+	}
+}
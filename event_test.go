@@ -0,0 +1,45 @@
+package consistent
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	c.Add("192.168.0.1")
+	c.Add("192.168.0.2")
+	c.Delete("192.168.0.1")
+	c.Delete("10.0.0.1")
+
+	want := []Event{
+		{Type: Added, Node: "192.168.0.1"},
+		{Type: Added, Node: "192.168.0.2"},
+		{Type: Removed, Node: "192.168.0.1"},
+	}
+	var got []Event
+	for len(got) < len(want) {
+		got = append(got, <-c.Events())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	select {
+	case e := <-c.Events():
+		t.Fatalf("unexpected event %v", e)
+	default:
+	}
+}
+
+func TestEventsDrop(t *testing.T) {
+	c := New().(*consistent)
+	// 没有人读取时，超过缓冲区的事件被丢弃，不会阻塞
+	for i := 0; i < eventBuffer*2; i++ {
+		c.Add(fmt.Sprintf("node-%d", i))
+	}
+	if n := len(c.Events()); n != eventBuffer {
+		t.Fatalf("buffered events = %d, want %d", n, eventBuffer)
+	}
+}
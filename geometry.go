@@ -0,0 +1,79 @@
+package consistent
+
+// Range 表示圆环上一段连续的哈希值范围，Start 和 End 都包含在范围内
+type Range struct {
+	Start, End uint64
+}
+
+// Ranges 获取每个节点在圆环上负责的哈希值范围
+// 每个副本负责从上一个副本的位置 (不包含) 到自身位置 (包含) 之间的范围，
+// 第一个副本还负责最后一个副本之后到圆环末尾的范围，相邻的范围会被合并，
+// 所有节点的范围连接起来正好覆盖整个圆环
+func (c *consistent) Ranges() map[string][]Range {
+	c.rlock()
+	defer c.RUnlock()
+	res := make(map[string][]Range, len(c.nodes))
+	if c.circle.Len() == 0 {
+		return res
+	}
+	appendRange := func(node string, r Range) {
+		ranges := res[node]
+		// 与该节点的上一个范围相邻则合并
+		if n := len(ranges); n > 0 && ranges[n-1].End+1 == r.Start {
+			ranges[n-1].End = r.End
+			return
+		}
+		res[node] = append(ranges, r)
+	}
+
+	start := uint64(0)
+	for _, pos := range c.circle {
+		appendRange(c.servers[pos], Range{Start: start, End: pos})
+		start = pos + 1
+	}
+	// 最后一个副本之后的范围属于第一个副本
+	if last := c.circle[c.circle.Len()-1]; last < c.maxPos {
+		appendRange(c.servers[c.circle[0]], Range{Start: last + 1, End: c.maxPos})
+	}
+	return res
+}
+
+// LoadFactor 获取节点负责的哈希值范围占整个圆环的比例，再除以平均比例 1/len(nodes)
+// 1.0 表示完全均衡，1.5 表示比平均多负责了 50% 的范围，节点不存在时返回 0；
+// 只根据副本的位置计算，不需要对 key 进行采样
+func (c *consistent) LoadFactor(node string) float64 {
+	c.rlock()
+	defer c.RUnlock()
+	m, ok := c.nodes[node]
+	if !ok || c.circle.Len() == 0 {
+		return 0
+	}
+	return c.owned(m) * float64(len(c.nodes))
+}
+
+// owned 计算节点负责的哈希值范围占整个圆环的比例，必须在锁内调用并且圆环不为空
+func (c *consistent) owned(m *member) float64 {
+	last := c.circle[c.circle.Len()-1]
+	owned := 0.0
+	for _, pos := range m.positions {
+		i := c.circle.search(pos)
+		if i == 0 {
+			// 第一个副本还负责最后一个副本之后到圆环末尾的范围
+			owned += float64(pos) + 1 + float64(c.maxPos-last)
+			continue
+		}
+		owned += float64(pos - c.circle[i-1])
+	}
+	return owned / (float64(c.maxPos) + 1)
+}
+
+// RingPositions 获取圆环上所有的副本位置以及所属的节点，按照位置从小到大排序，也就是顺时针的顺序
+// 相邻两个位置之间的范围属于后一个位置的节点，可以对照 Ranges 理解每个节点负责的范围
+func (c *consistent) RingPositions() []Position {
+	r := c.load()
+	res := make([]Position, r.circle.Len())
+	for i, pos := range r.circle {
+		res[i] = Position{Node: r.servers[pos], Pos: pos}
+	}
+	return res
+}
@@ -0,0 +1,85 @@
+package consistent
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestRanges(t *testing.T) {
+	c := New(WithReplicas(3)).(*consistent)
+	c.Add("192.168.0.1")
+	c.Add("192.168.0.2")
+	c.Add("192.168.0.3")
+
+	var all []Range
+	for node, ranges := range c.Ranges() {
+		for _, r := range ranges {
+			if r.Start > r.End {
+				t.Fatalf("%s has an invalid range %+v", node, r)
+			}
+			// 范围内的值都属于该节点
+			for _, v := range []uint64{r.Start, r.End} {
+				if owner := c.servers[c.circle[c.circle.search(v)]]; owner != node {
+					t.Fatalf("value %d in range of %s is owned by %s", v, node, owner)
+				}
+			}
+		}
+		all = append(all, ranges...)
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Start < all[j].Start })
+	if all[0].Start != 0 {
+		t.Fatalf("first range starts at %d, want 0", all[0].Start)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Start != all[i-1].End+1 {
+			t.Fatalf("range %+v does not follow %+v", all[i], all[i-1])
+		}
+	}
+	if last := all[len(all)-1].End; last != c.maxPos {
+		t.Fatalf("last range ends at %d, want %d", last, c.maxPos)
+	}
+
+	if n := len(New().(*consistent).Ranges()); n != 0 {
+		t.Fatalf("Ranges on empty ring returned %d nodes", n)
+	}
+}
+
+func TestLoadFactor(t *testing.T) {
+	positions := map[string]uint32{"a#0": 0x40000000, "a#1": 0x80000000, "b#0": 0xA0000000, "b#1": 0xC0000000}
+	c := New(WithHash(func(name string) uint32 {
+		return positions[name]
+	}), WithReplicas(2)).(*consistent)
+	if f := c.LoadFactor("a"); f != 0 {
+		t.Fatalf("LoadFactor on empty ring = %v, want 0", f)
+	}
+	c.AddBatch("a", "b")
+
+	// a 负责 [0, 0x80000000] 以及 (0xC0000000, 0xFFFFFFFF]，一共 3/4 的圆环
+	tests := map[string]float64{"a": 1.5, "b": 0.5, "c": 0}
+	for node, want := range tests {
+		if f := c.LoadFactor(node); f != want {
+			t.Errorf("LoadFactor(%q) = %v, want %v", node, f, want)
+		}
+	}
+}
+
+func TestRingPositions(t *testing.T) {
+	c := New().(*consistent)
+	if positions := c.RingPositions(); len(positions) != 0 {
+		t.Fatalf("RingPositions on empty ring = %v", positions)
+	}
+	c.AddBatch("192.168.0.1", "192.168.0.2", "192.168.0.3")
+	positions := c.RingPositions()
+	if len(positions) != c.VirtualNodes() {
+		t.Fatalf("len(RingPositions) = %d, want %d", len(positions), c.VirtualNodes())
+	}
+	if !sort.SliceIsSorted(positions, func(i, j int) bool { return positions[i].Pos < positions[j].Pos }) {
+		t.Fatal("RingPositions is not sorted by position")
+	}
+	for _, p := range positions {
+		if c.servers[p.Pos] != p.Node {
+			t.Fatalf("position %d belongs to %s, want %s", p.Pos, p.Node, c.servers[p.Pos])
+		}
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/junhaideng/consistent
 
-go 1.16
+go 1.19
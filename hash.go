@@ -0,0 +1,184 @@
+package consistent
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"hash/fnv"
+	"math/bits"
+)
+
+// crc32 Castagnoli 表，支持的平台上会使用硬件加速
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// WithSeededHash 使用混入了种子的默认哈希函数
+// 相同的种子以及相同的节点构建出来的圆环，Get 的结果完全一致，
+// 修改种子可以在不改变哈希算法的情况下重新打乱节点的分布
+func WithSeededHash(seed uint64) Option {
+	var prefix [8]byte
+	binary.LittleEndian.PutUint64(prefix[:], seed)
+	return WithHash(func(name string) uint32 {
+		f := fnv.New32()
+		f.Write(prefix[:])
+		f.Write([]byte(name))
+		return fmix32(f.Sum32())
+	})
+}
+
+// WithMurmur3 使用 murmur3 32 位哈希函数
+// 相比于默认的 fnv，murmur3 对于相差不多的 key 也能够分布得比较均匀
+func WithMurmur3() Option {
+	return WithHash(murmur3)
+}
+
+// murmur3 为 MurmurHash3_x86_32 的实现，种子为 0
+// 直接读取字符串中的字节，不会产生额外的内存分配
+func murmur3(name string) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	var h uint32
+	n := len(name)
+	// 每次处理 4 个字节
+	i := 0
+	for ; i+4 <= n; i += 4 {
+		k := readUint32(name, i)
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	// 处理剩余的字节
+	var k uint32
+	switch n - i {
+	case 3:
+		k ^= uint32(name[i+2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(name[i+1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(name[i])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(n)
+	return fmix32(h)
+}
+
+// WithCRC32 使用 crc32 Castagnoli (CRC32C) 哈希函数
+// 便于和同样使用 CRC32C 进行分区的系统保持一致
+func WithCRC32() Option {
+	return WithHash(crc32c)
+}
+
+func crc32c(name string) uint32 {
+	return crc32.Checksum([]byte(name), castagnoli)
+}
+
+// WithXXHash 使用 64 位的 xxhash 哈希函数，节点将分布在 64 位的圆环上
+// xxhash 速度快并且分布均匀，适合比较长的 key
+func WithXXHash() Option {
+	return WithHash64(xxhash)
+}
+
+// 使用变量而不是常量，使得计算时可以发生溢出
+var (
+	prime64v1 uint64 = 11400714785074694791
+	prime64v2 uint64 = 14029467366897019727
+	prime64v3 uint64 = 1609587929392839161
+	prime64v4 uint64 = 9650029242287828579
+	prime64v5 uint64 = 2870177450012600261
+)
+
+// xxhash 为 XXH64 的实现，种子为 0
+// 直接读取字符串中的字节，不会产生额外的内存分配
+func xxhash(name string) uint64 {
+	n := len(name)
+	i := 0
+	var h uint64
+	if n >= 32 {
+		v1 := prime64v1 + prime64v2
+		v2 := prime64v2
+		v3 := uint64(0)
+		v4 := -prime64v1
+		for ; i+32 <= n; i += 32 {
+			v1 = xxhashRound(v1, readUint64(name, i))
+			v2 = xxhashRound(v2, readUint64(name, i+8))
+			v3 = xxhashRound(v3, readUint64(name, i+16))
+			v4 = xxhashRound(v4, readUint64(name, i+24))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxhashMergeRound(h, v1)
+		h = xxhashMergeRound(h, v2)
+		h = xxhashMergeRound(h, v3)
+		h = xxhashMergeRound(h, v4)
+	} else {
+		h = prime64v5
+	}
+	h += uint64(n)
+
+	for ; i+8 <= n; i += 8 {
+		h ^= xxhashRound(0, readUint64(name, i))
+		h = bits.RotateLeft64(h, 27)*prime64v1 + prime64v4
+	}
+	if i+4 <= n {
+		h ^= uint64(readUint32(name, i)) * prime64v1
+		h = bits.RotateLeft64(h, 23)*prime64v2 + prime64v3
+		i += 4
+	}
+	for ; i < n; i++ {
+		h ^= uint64(name[i]) * prime64v5
+		h = bits.RotateLeft64(h, 11) * prime64v1
+	}
+
+	h ^= h >> 33
+	h *= prime64v2
+	h ^= h >> 29
+	h *= prime64v3
+	h ^= h >> 32
+	return h
+}
+
+func xxhashRound(acc, input uint64) uint64 {
+	acc += input * prime64v2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * prime64v1
+}
+
+func xxhashMergeRound(acc, val uint64) uint64 {
+	acc ^= xxhashRound(0, val)
+	return acc*prime64v1 + prime64v4
+}
+
+// readUint64 以小端序读取字符串中从 i 开始的 8 个字节
+func readUint64(s string, i int) uint64 {
+	return uint64(s[i]) | uint64(s[i+1])<<8 | uint64(s[i+2])<<16 | uint64(s[i+3])<<24 |
+		uint64(s[i+4])<<32 | uint64(s[i+5])<<40 | uint64(s[i+6])<<48 | uint64(s[i+7])<<56
+}
+
+// readUint32 以小端序读取字符串中从 i 开始的 4 个字节
+func readUint32(s string, i int) uint32 {
+	return uint32(s[i]) | uint32(s[i+1])<<8 | uint32(s[i+2])<<16 | uint32(s[i+3])<<24
+}
+
+// WithFNV1a64 使用 FNV-1a 64 位哈希函数，圆环的位置为 64 位
+// FNV-1 对每个字节先乘以质数再异或，最后一个字节只参与了一次异或，只影响结果的低位；
+// FNV-1a 先异或再乘以质数，最后一个字节也会经过一次乘法扩散到高位，
+// 所以对于只有末尾几个字符不同的 key (例如 "key-1", "key-2")，FNV-1a 分布得更加均匀
+func WithFNV1a64() Option {
+	return WithHash64(fnv1a64)
+}
+
+// fnv1a64 为 FNV-1a 64 位哈希函数
+func fnv1a64(name string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(name))
+	return f.Sum64()
+}
@@ -0,0 +1,269 @@
+package consistent
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSeededHash(t *testing.T) {
+	build := func(seed uint64) ConsistentHasher {
+		c := New(WithSeededHash(seed))
+		for i := 0; i < 10; i++ {
+			c.Add(fmt.Sprintf("192.168.0.%d", i))
+		}
+		return c
+	}
+
+	a, b, other := build(42), build(42), build(7)
+	diff := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if a.Get(key) != b.Get(key) {
+			t.Fatalf("Get(%q) differs between rings with the same seed", key)
+		}
+		if a.Get(key) != other.Get(key) {
+			diff++
+		}
+	}
+	if diff == 0 {
+		t.Fatal("rings with different seeds produce identical placement")
+	}
+}
+
+func TestDefaultHashDistribution(t *testing.T) {
+	c := New().(*consistent)
+	fnv := New(WithHash(fnv32)).(*consistent)
+	for i := 0; i < 10; i++ {
+		c.Add(fmt.Sprintf("user-%d", i))
+		fnv.Add(fmt.Sprintf("user-%d", i))
+	}
+	stddev := c.LoadStdDev(100000)
+	t.Logf("default: %.4f, fnv: %.4f", stddev, fnv.LoadStdDev(100000))
+	if stddev > 0.35 {
+		t.Fatalf("default hash stddev for sequential keys = %.4f, want <= 0.35", stddev)
+	}
+}
+
+func TestMurmur3(t *testing.T) {
+	tests := []struct {
+		input string
+		want  uint32
+	}{
+		{"", 0},
+		{"hello", 0x248bfa47},
+		{"Hello, world!", 0xc0363e43},
+		{"The quick brown fox jumps over the lazy dog", 0x2e4ff723},
+	}
+	for _, tt := range tests {
+		if got := murmur3(tt.input); got != tt.want {
+			t.Errorf("murmur3(%q) = %#x, want %#x", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestMurmur3Distribution(t *testing.T) {
+	fnv := New(WithHash(fnv32)).(*consistent)
+	murmur := New(WithMurmur3()).(*consistent)
+	for i := 0; i < 10; i++ {
+		fnv.Add(fmt.Sprintf("user-%d", i))
+		murmur.Add(fmt.Sprintf("user-%d", i))
+	}
+
+	fnvStdDev := fnv.LoadStdDev(100000)
+	murmurStdDev := murmur.LoadStdDev(100000)
+	t.Logf("fnv: %.4f, murmur3: %.4f", fnvStdDev, murmurStdDev)
+	if murmurStdDev >= fnvStdDev {
+		t.Fatalf("murmur3 stddev %.4f is not lower than fnv stddev %.4f", murmurStdDev, fnvStdDev)
+	}
+}
+
+func TestCRC32(t *testing.T) {
+	if got := crc32c("123456789"); got != 0xe3069283 {
+		t.Fatalf("crc32c(%q) = %#x, want %#x", "123456789", got, 0xe3069283)
+	}
+
+	a := New(WithCRC32()).(*consistent)
+	b := New(WithCRC32()).(*consistent)
+	for i := 0; i < 10; i++ {
+		a.Add(fmt.Sprintf("192.168.0.%d", i))
+		b.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	if !reflect.DeepEqual(a.circle, b.circle) {
+		t.Fatal("identical rings have different positions")
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if a.Get(key) != b.Get(key) {
+			t.Fatalf("Get(%q) differs between identical rings", key)
+		}
+	}
+}
+
+func TestXXHash(t *testing.T) {
+	tests := []struct {
+		input string
+		want  uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+		{"abcdefghijklmnopqrstuvwxyz012345", 0xbf2cd639b4143b80},
+		{strings.Repeat("0123456789", 10), 0xf80e7b96315afffa},
+	}
+	for _, tt := range tests {
+		if got := xxhash(tt.input); got != tt.want {
+			t.Errorf("xxhash(%q) = %#x, want %#x", tt.input, got, tt.want)
+		}
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { xxhash("Nobody inspects the spammish repetition") }); allocs != 0 {
+		t.Fatalf("xxhash allocates %.0f times per call, want 0", allocs)
+	}
+}
+
+func BenchmarkGetHash(b *testing.B) {
+	// 长度大约为 1KB 的 key
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strings.Repeat("k", 1024) + strconv.Itoa(i)
+	}
+	hashes := []struct {
+		name   string
+		option Option
+	}{
+		{"fnv", WithHash(hash)},
+		{"xxhash", WithXXHash()},
+	}
+	for _, h := range hashes {
+		c := New(h.option)
+		for i := 0; i < 100; i++ {
+			c.Add(fmt.Sprintf("nodes-%d", i))
+		}
+		b.Run(h.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				c.Get(keys[i%len(keys)])
+			}
+		})
+	}
+}
+
+func TestFNV1a64Distribution(t *testing.T) {
+	fnv := New(WithHash(fnv32)).(*consistent)
+	fnv1a := New(WithFNV1a64()).(*consistent)
+	for i := 0; i < 10; i++ {
+		fnv.Add(fmt.Sprintf("user-%d", i))
+		fnv1a.Add(fmt.Sprintf("user-%d", i))
+	}
+	if fnv1a.maxPos != math.MaxUint64 {
+		t.Fatalf("maxPos = %#x, want 64-bit ring", fnv1a.maxPos)
+	}
+
+	fnvStdDev := fnv.LoadStdDev(100000)
+	fnv1aStdDev := fnv1a.LoadStdDev(100000)
+	t.Logf("fnv-1 32: %.4f, fnv-1a 64: %.4f", fnvStdDev, fnv1aStdDev)
+	if fnv1aStdDev >= fnvStdDev {
+		t.Fatalf("fnv-1a stddev %.4f is not lower than fnv-1 stddev %.4f", fnv1aStdDev, fnvStdDev)
+	}
+}
+
+func TestWithHashFunc(t *testing.T) {
+	tests := []struct {
+		name   string
+		option Option
+		maxPos uint64
+	}{
+		{"func32", WithHashFunc(func(name string) uint32 { return murmur3(name) }), math.MaxUint32},
+		{"Hash", WithHashFunc(Hash(crc32c)), math.MaxUint32},
+		{"func64", WithHashFunc(func(name string) uint64 { return xxhash(name) }), math.MaxUint64},
+		{"Hash64", WithHashFunc(Hash64(fnv1a64)), math.MaxUint64},
+		{"WithHash32", WithHash32(murmur3), math.MaxUint32},
+		{"WithHash64", WithHash64(xxhash), math.MaxUint64},
+	}
+	for _, tt := range tests {
+		c := New(tt.option).(*consistent)
+		if c.maxPos != tt.maxPos {
+			t.Errorf("%s: maxPos = %#x, want %#x", tt.name, c.maxPos, tt.maxPos)
+		}
+		c.AddBatch("192.168.0.1", "192.168.0.2", "192.168.0.3")
+		if c.Get("key") == "" || len(c.GetN("key", 3)) != 3 {
+			t.Errorf("%s: Get on populated ring failed", tt.name)
+		}
+		if err := c.Validate(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestWithHashFactory(t *testing.T) {
+	calls := 0
+	factory := func() Hash {
+		calls++
+		seed := uint32(calls)
+		return func(name string) uint32 {
+			return murmur3(name) ^ seed*0x9e3779b9
+		}
+	}
+	a := New(WithHashFactory(factory), WithWeights(map[string]int{"192.168.0.1": 1, "192.168.0.2": 1}))
+	if calls != 1 {
+		t.Fatalf("factory called %d times, want 1", calls)
+	}
+	b := New(WithHashFactory(factory), WithWeights(map[string]int{"192.168.0.1": 1, "192.168.0.2": 1}))
+	if calls != 2 {
+		t.Fatalf("factory called %d times, want 2", calls)
+	}
+
+	// 两个实例的哈希函数互相独立，位置不同
+	if reflect.DeepEqual(a.(*consistent).circle, b.(*consistent).circle) {
+		t.Fatal("rings built from the same factory share a hash")
+	}
+	if a.(*consistent).hash("key") == b.(*consistent).hash("key") {
+		t.Fatal("hash functions are not independent")
+	}
+
+	// 划分子圆环时只调用一次，所有的子圆环共用同一个哈希函数
+	s := New(WithHashFactory(factory), WithShards(4)).(*sharded)
+	if calls != 3 {
+		t.Fatalf("factory called %d times with WithShards, want 3", calls)
+	}
+	for i, shard := range s.shards {
+		if shard.hash("key") != s.hash("key") {
+			t.Fatalf("shard %d uses a different hash", i)
+		}
+	}
+}
+
+func TestWithNamespace(t *testing.T) {
+	nodes := WithInitialNodes("192.168.0.1", "192.168.0.2", "192.168.0.3", "192.168.0.4")
+	a := New(nodes, WithNamespace("tenant-a")).(*consistent)
+	b := New(WithNamespace("tenant-b"), nodes).(*consistent)
+	plain := New(nodes).(*consistent)
+	if reflect.DeepEqual(a.circle, b.circle) || reflect.DeepEqual(a.circle, plain.circle) {
+		t.Fatal("namespaced rings share node placement")
+	}
+	differs := 0
+	for i := 0; i < 1000; i++ {
+		if key := sampleKey(i); a.Get(key) != b.Get(key) {
+			differs++
+		}
+	}
+	// 分布相互独立时大约 3/4 的 key 分配到不同的节点
+	t.Logf("%d of 1000 keys differ", differs)
+	if differs < 500 {
+		t.Fatalf("only %d of 1000 keys are assigned differently", differs)
+	}
+
+	// 相同的命名空间得到相同的布局，与参数选项的顺序以及哈希选项无关
+	again := New(WithNamespace("tenant-a"), nodes).(*consistent)
+	if !a.Equal(again) {
+		t.Fatal("rings with the same namespace differ")
+	}
+	a64 := New(WithNamespace("tenant-a"), WithHash64(xxhash), nodes).(*consistent)
+	if a64.hash("key") != xxhash("8:tenant-akey") {
+		t.Fatal("namespace is not mixed into the 64-bit hash")
+	}
+}
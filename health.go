@@ -0,0 +1,52 @@
+package consistent
+
+// MarkDown 将节点标记为不可用，GetHealthy 会跳过该节点，节点在圆环上的位置不会发生变化，
+// 所以其他节点上的 key 不会迁移；标记在 Add 和 Delete 之后仍然保留，只有 MarkUp 和 Reset 会清除，
+// 节点已经被标记时不做任何处理
+func (c *consistent) MarkDown(slot string) {
+	c.Lock()
+	defer c.Unlock()
+	if c.down[slot] {
+		return
+	}
+	if c.down == nil {
+		c.down = make(map[string]bool)
+	}
+	c.down[slot] = true
+	c.publish()
+}
+
+// MarkUp 取消节点的不可用标记，之后 GetHealthy 重新选择该节点，节点没有被标记时不做任何处理
+func (c *consistent) MarkUp(slot string) {
+	c.Lock()
+	defer c.Unlock()
+	if !c.down[slot] {
+		return
+	}
+	delete(c.down, slot)
+	c.publish()
+}
+
+// GetHealthy 获取 key 所在的可用节点，从 key 所在的位置开始顺时针跳过被 MarkDown 标记的节点，
+// 没有节点被标记时与 Get 的结果一致，固定分配的节点不可用时同样按照圆环查找，
+// 如果圆环上没有任何可用的节点，返回空字符串
+func (c *consistent) GetHealthy(key string) string {
+	c.gets.Add(1)
+	r := c.load()
+	if len(r.down) == 0 {
+		server, _ := r.lookup(key, c.hash)
+		return server
+	}
+	if pinned, ok := r.pins[key]; ok && !r.down[pinned] {
+		return pinned
+	}
+	var res string
+	r.walk(c.hash(key), func(server string) bool {
+		if r.down[server] {
+			return true
+		}
+		res = server
+		return false
+	})
+	return res
+}
@@ -0,0 +1,72 @@
+package consistent
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMarkDown(t *testing.T) {
+	c := New().(*consistent)
+	for i := 0; i < 5; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	circle := append(uints(nil), c.circle...)
+	const samples = 1000
+	c.MarkDown("192.168.0.1")
+	c.MarkDown("192.168.0.1")
+	if !reflect.DeepEqual(c.circle, circle) {
+		t.Fatal("MarkDown changed the positions on the ring")
+	}
+	moved := 0
+	for i := 0; i < samples; i++ {
+		key := sampleKey(i)
+		nodes := c.GetN(key, 2)
+		want := nodes[0]
+		if want == "192.168.0.1" {
+			// 不可用的节点上的 key 顺时针转移到下一个可用的节点
+			want = nodes[1]
+			moved++
+		}
+		if server := c.GetHealthy(key); server != want {
+			t.Fatalf("GetHealthy(%q) = %s, want %s", key, server, want)
+		}
+		if server := c.Get(key); server != nodes[0] {
+			t.Fatalf("Get(%q) = %s after MarkDown, want %s", key, server, nodes[0])
+		}
+	}
+	if moved == 0 {
+		t.Fatal("no sample key belongs to the node marked down")
+	}
+
+	// Rename 之后仍然不可用，原来的名称不再保留标记
+	c.Rename("192.168.0.1", "host-1")
+	if !c.down["host-1"] || c.down["192.168.0.1"] {
+		t.Fatalf("down marks after Rename = %v, want host-1", c.down)
+	}
+	for i := 0; i < samples; i++ {
+		if key := sampleKey(i); c.GetHealthy(key) == "host-1" {
+			t.Fatalf("GetHealthy(%q) returned the renamed node marked down", key)
+		}
+	}
+	c.Rename("host-1", "192.168.0.1")
+
+	c.MarkUp("192.168.0.1")
+	for i := 0; i < samples; i++ {
+		if key := sampleKey(i); c.GetHealthy(key) != c.Get(key) {
+			t.Fatalf("GetHealthy(%q) = %s after MarkUp, want %s", key, c.GetHealthy(key), c.Get(key))
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		c.MarkDown(fmt.Sprintf("192.168.0.%d", i))
+	}
+	if server := c.GetHealthy("key"); server != "" {
+		t.Fatalf("GetHealthy with all nodes down = %q, want empty", server)
+	}
+	c.Reset()
+	c.Add("192.168.0.1")
+	if server := c.GetHealthy("key"); server != "192.168.0.1" {
+		t.Fatalf("GetHealthy after Reset = %q, want 192.168.0.1", server)
+	}
+}
@@ -0,0 +1,111 @@
+package consistent
+
+import (
+	"strconv"
+	"sync"
+)
+
+// JumpHash 为 Google 的 jump consistent hash 算法，将 key 映射到 [0, buckets) 中的一个桶
+// 不需要额外的内存，桶的数量从 n 增加到 n+1 时只有 1/(n+1) 的 key 会发生迁移，
+// buckets 不为正数时返回 -1
+func JumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// jump 将 JumpHash 包装成 ConsistentHasher
+// 节点只能是 "0" 到 "buckets-1" 的编号，而不是任意的名称
+type jump struct {
+	// 桶的数量
+	buckets int
+	// 采用的hash算法
+	hash Hash64
+	sync.RWMutex
+}
+
+// NewJump 创建新的 jump consistent hash 实例，初始拥有 buckets 个桶
+// Get 先使用默认的哈希函数将 key 转换成整数，再通过 JumpHash 得到桶的编号，
+// Add 和 Delete 只能调整桶的数量：Add 只接受下一个编号 strconv.Itoa(buckets)，
+// Delete 只能删除最后一个编号 strconv.Itoa(buckets-1)，其他的参数不做任何处理
+func NewJump(buckets int) ConsistentHasher {
+	if buckets < 0 {
+		buckets = 0
+	}
+	return &jump{
+		buckets: buckets,
+		hash:    config().hash,
+	}
+}
+
+// Add 在末尾添加一个桶，slot 必须为当前桶的数量
+func (j *jump) Add(slot string) bool {
+	j.Lock()
+	defer j.Unlock()
+	if slot != strconv.Itoa(j.buckets) {
+		return false
+	}
+	j.buckets++
+	return true
+}
+
+// Delete 删除最后一个桶，slot 必须为最后一个桶的编号
+func (j *jump) Delete(slot string) {
+	j.Lock()
+	defer j.Unlock()
+	if j.buckets > 0 && slot == strconv.Itoa(j.buckets-1) {
+		j.buckets--
+	}
+}
+
+// Get 获取 key 所在的桶的编号，如果没有任何桶，返回空字符串
+func (j *jump) Get(key string) string {
+	j.RLock()
+	defer j.RUnlock()
+	if j.buckets == 0 {
+		return ""
+	}
+	return strconv.Itoa(JumpHash(j.hash(key), j.buckets))
+}
+
+// GetN 获取 key 所在的桶以及之后的 n-1 个桶的编号，超过最后一个桶时从 0 开始
+func (j *jump) GetN(key string, n int) []string {
+	j.RLock()
+	defer j.RUnlock()
+	if n <= 0 || j.buckets == 0 {
+		return nil
+	}
+	if n > j.buckets {
+		n = j.buckets
+	}
+	b := JumpHash(j.hash(key), j.buckets)
+	res := make([]string, n)
+	for i := range res {
+		res[i] = strconv.Itoa((b + i) % j.buckets)
+	}
+	return res
+}
+
+// Len 获取桶的数量
+func (j *jump) Len() int {
+	j.RLock()
+	defer j.RUnlock()
+	return j.buckets
+}
+
+// Close 没有需要释放的资源，总是返回 nil
+func (j *jump) Close() error {
+	return nil
+}
+
+// Contains 判断桶是否存在，只有 "0" 到 "buckets-1" 的编号存在，"01" 这样的写法也视为不存在
+func (j *jump) Contains(slot string) bool {
+	j.RLock()
+	defer j.RUnlock()
+	b, err := strconv.Atoi(slot)
+	return err == nil && b >= 0 && b < j.buckets && slot == strconv.Itoa(b)
+}
@@ -0,0 +1,68 @@
+package consistent
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestJumpHash(t *testing.T) {
+	// 参考实现的测试数据
+	tests := []struct {
+		key     uint64
+		buckets int
+		want    int
+	}{
+		{1, 1, 0},
+		{42, 57, 43},
+		{0xDEAD10CC, 1, 0},
+		{0xDEAD10CC, 666, 361},
+		{256, 1024, 520},
+	}
+	for _, tt := range tests {
+		if got := JumpHash(tt.key, tt.buckets); got != tt.want {
+			t.Errorf("JumpHash(%d, %d) = %d, want %d", tt.key, tt.buckets, got, tt.want)
+		}
+	}
+	if got := JumpHash(1, 0); got != -1 {
+		t.Errorf("JumpHash(1, 0) = %d, want -1", got)
+	}
+}
+
+func TestJump(t *testing.T) {
+	j := NewJump(0)
+	if server := j.Get("key"); server != "" {
+		t.Fatalf("Get with no buckets = %q, want empty", server)
+	}
+	if j.Add("1") {
+		t.Fatal("Add(1) with no buckets returned true")
+	}
+	for i := 0; i < 10; i++ {
+		if !j.Add(fmt.Sprint(i)) {
+			t.Fatalf("Add(%d) returned false", i)
+		}
+	}
+
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = j.Get(key)
+		if !j.Contains(before[key]) {
+			t.Fatalf("Get(%q) = %s, not a valid bucket", key, before[key])
+		}
+	}
+
+	// 只能删除最后一个桶，删除之后只有该桶中的 key 发生迁移
+	j.Delete("0")
+	if !j.Contains("0") {
+		t.Fatal("Delete removed a bucket other than the last one")
+	}
+	j.Delete("9")
+	if j.Contains("9") {
+		t.Fatal("Contains(9) returned true after Delete")
+	}
+	for key, bucket := range before {
+		if bucket != "9" && j.Get(key) != bucket {
+			t.Fatalf("key %q moved from bucket %s to %s", key, bucket, j.Get(key))
+		}
+	}
+}
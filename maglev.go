@@ -0,0 +1,184 @@
+package consistent
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrInvalidTableSize Maglev 查找表的大小不是质数
+var ErrInvalidTableSize = errors.New("consistent: maglev table size must be prime")
+
+// maglev 为 Google Maglev 负载均衡器中的一致性哈希实现
+// 每个节点根据名称生成一个 [0, tableSize) 的排列，所有节点轮流按照各自的排列填充查找表，
+// 每个节点占据的表项数量最多相差 1，Get 只需要一次哈希和一次数组访问，
+// 删除节点时除了该节点的表项之外，只有少量其他表项会发生变化
+type maglev struct {
+	// 查找表的大小，必须为质数
+	size uint64
+	// 所有的节点，按照名称排序
+	names []string
+	// 查找表，保存节点在 names 中的下标
+	table []int
+	// 采用的hash算法
+	hash Hash64
+	sync.RWMutex
+}
+
+// NewMaglev 创建新的 Maglev 哈希实例，查找表的大小为 tableSize
+// tableSize 必须为质数，否则 panic ErrInvalidTableSize，通常应当远大于节点的数量，例如 65537，
+// 支持 WithHash, WithHash64 以及 WithWeights 参数选项，WithWeights 只用于添加初始的节点，权重会被忽略，
+// Add 和 Delete 会重新计算整个查找表，时间复杂度为 O(tableSize)
+func NewMaglev(tableSize int, options ...Option) ConsistentHasher {
+	if !isPrime(tableSize) {
+		panic(ErrInvalidTableSize)
+	}
+	c := config(options...)
+	m := &maglev{
+		size: uint64(tableSize),
+		hash: c.hash,
+	}
+	for node, weight := range c.weights {
+		if weight > 0 {
+			m.names = append(m.names, node)
+		}
+	}
+	sort.Strings(m.names)
+	m.populate()
+	return m
+}
+
+// isPrime 判断 n 是否为质数
+func isPrime(n int) bool {
+	if n < 2 {
+		return false
+	}
+	for i := 2; i*i <= n; i++ {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// populate 根据当前的节点重新计算查找表
+func (m *maglev) populate() {
+	if len(m.names) == 0 {
+		m.table = nil
+		return
+	}
+	// 每个节点的排列为 (offset + j * skip) % size，size 为质数保证可以遍历所有的表项
+	offsets := make([]uint64, len(m.names))
+	skips := make([]uint64, len(m.names))
+	for i, node := range m.names {
+		offsets[i] = m.hash(node+"#offset") % m.size
+		skips[i] = m.hash(node+"#skip")%(m.size-1) + 1
+	}
+	next := make([]uint64, len(m.names))
+	table := make([]int, m.size)
+	for i := range table {
+		table[i] = -1
+	}
+	for filled := uint64(0); ; {
+		for i := range m.names {
+			c := (offsets[i] + next[i]*skips[i]) % m.size
+			for table[c] >= 0 {
+				next[i]++
+				c = (offsets[i] + next[i]*skips[i]) % m.size
+			}
+			table[c] = i
+			next[i]++
+			filled++
+			if filled == m.size {
+				m.table = table
+				return
+			}
+		}
+	}
+}
+
+// Add 添加一个节点并重新计算查找表，如果节点已经存在，不做任何处理并返回 false
+func (m *maglev) Add(slot string) bool {
+	m.Lock()
+	defer m.Unlock()
+	i := sort.SearchStrings(m.names, slot)
+	if i < len(m.names) && m.names[i] == slot {
+		return false
+	}
+	m.names = append(m.names, "")
+	copy(m.names[i+1:], m.names[i:])
+	m.names[i] = slot
+	m.populate()
+	return true
+}
+
+// Delete 删除一个节点并重新计算查找表
+func (m *maglev) Delete(slot string) {
+	m.Lock()
+	defer m.Unlock()
+	i := sort.SearchStrings(m.names, slot)
+	if i == len(m.names) || m.names[i] != slot {
+		return
+	}
+	m.names = append(m.names[:i], m.names[i+1:]...)
+	m.populate()
+}
+
+// Get 获取 key 所在的节点，如果没有任何节点，返回空字符串
+func (m *maglev) Get(key string) string {
+	m.RLock()
+	defer m.RUnlock()
+	if len(m.table) == 0 {
+		return ""
+	}
+	return m.names[m.table[m.hash(key)%m.size]]
+}
+
+// GetN 从 key 所在的表项开始依次向后查找，获取 n 个不同的节点，第一个元素与 Get 的结果一致
+func (m *maglev) GetN(key string, n int) []string {
+	m.RLock()
+	defer m.RUnlock()
+	if n <= 0 || len(m.table) == 0 {
+		return nil
+	}
+	if n > len(m.names) {
+		n = len(m.names)
+	}
+	res := make([]string, 0, n)
+	seen := make(map[int]bool, n)
+	for i, start := uint64(0), m.hash(key)%m.size; i < m.size && len(res) < n; i++ {
+		node := m.table[(start+i)%m.size]
+		if !seen[node] {
+			seen[node] = true
+			res = append(res, m.names[node])
+		}
+	}
+	return res
+}
+
+// Contains 判断节点是否存在
+func (m *maglev) Contains(slot string) bool {
+	m.RLock()
+	defer m.RUnlock()
+	i := sort.SearchStrings(m.names, slot)
+	return i < len(m.names) && m.names[i] == slot
+}
+
+// Len 获取节点的数量
+func (m *maglev) Len() int {
+	m.RLock()
+	defer m.RUnlock()
+	return len(m.names)
+}
+
+// Close 没有需要释放的资源，总是返回 nil
+func (m *maglev) Close() error {
+	return nil
+}
+
+// Members 获取到所有的节点，按照名称排序
+func (m *maglev) Members() []string {
+	m.RLock()
+	defer m.RUnlock()
+	return append([]string(nil), m.names...)
+}
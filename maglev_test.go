@@ -0,0 +1,72 @@
+package consistent
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMaglev(t *testing.T) {
+	const size = 65537
+	m := NewMaglev(size).(*maglev)
+	if server := m.Get("key"); server != "" {
+		t.Fatalf("Get on empty maglev = %q, want empty", server)
+	}
+	for i := 0; i < 10; i++ {
+		if !m.Add(fmt.Sprintf("192.168.0.%d", i)) {
+			t.Fatalf("Add(192.168.0.%d) returned false", i)
+		}
+	}
+	if m.Add("192.168.0.0") {
+		t.Fatal("duplicate Add returned true")
+	}
+
+	// 每个节点占据的表项数量最多相差 1
+	fill := make(map[int]int)
+	for _, node := range m.table {
+		fill[node]++
+	}
+	for node, count := range fill {
+		if count < size/10 || count > size/10+1 {
+			t.Fatalf("%s fills %d entries, want %d or %d", m.names[node], count, size/10, size/10+1)
+		}
+	}
+
+	const samples = 10000
+	before := make(map[string]string, samples)
+	for i := 0; i < samples; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		nodes := m.GetN(key, 3)
+		if len(nodes) != 3 || nodes[0] != m.Get(key) || nodes[0] == nodes[1] || nodes[1] == nodes[2] {
+			t.Fatalf("GetN(%q, 3) = %v, Get = %s", key, nodes, m.Get(key))
+		}
+		before[key] = nodes[0]
+	}
+
+	m.Delete("192.168.0.0")
+	if m.Contains("192.168.0.0") {
+		t.Fatal("Contains returned true after Delete")
+	}
+	moved, disrupted := 0, 0
+	for key, owner := range before {
+		if m.Get(key) != owner {
+			moved++
+			if owner != "192.168.0.0" {
+				disrupted++
+			}
+		}
+	}
+	t.Logf("moved %d keys, %d from surviving nodes", moved, disrupted)
+	if disrupted > samples/100 {
+		t.Fatalf("%d keys moved between surviving nodes, want at most %d", disrupted, samples/100)
+	}
+}
+
+func TestMaglevTableSize(t *testing.T) {
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrInvalidTableSize) {
+			t.Fatalf("NewMaglev(65536) recovered %v, want ErrInvalidTableSize", err)
+		}
+	}()
+	NewMaglev(65536)
+}
@@ -0,0 +1,141 @@
+package consistent
+
+// MovedKeys 计算 keys 中在两个圆环上所属节点不同的 key
+// 返回的结果中，值的第一个元素为 key 在 old 上所属的节点，第二个元素为在 new 上所属的节点
+func MovedKeys(old, new ConsistentHasher, keys []string) map[string][2]string {
+	res := make(map[string][2]string)
+	for _, key := range keys {
+		from, to := old.Get(key), new.Get(key)
+		if from != to {
+			res[key] = [2]string{from, to}
+		}
+	}
+	return res
+}
+
+// MovedFraction 使用 samples 个样本 key 估算两个圆环之间需要迁移的 key 的比例
+// 样本 key 与 Distribution 使用的一致
+func MovedFraction(old, new ConsistentHasher, samples int) float64 {
+	if samples <= 0 {
+		return 0
+	}
+	moved := 0
+	for i := 0; i < samples; i++ {
+		key := sampleKey(i)
+		if old.Get(key) != new.Get(key) {
+			moved++
+		}
+	}
+	return float64(moved) / float64(samples)
+}
+
+// SimulateRemove 模拟删除节点，使用 samples 个样本 key 估算需要迁移的 key 的比例以及每个节点上 key 数量的变化
+// 在圆环的副本上进行删除，不会修改当前的圆环，也不会触发任何通知，
+// perNodeDelta 中只包含 key 数量发生变化的节点，被删除的节点为负数，节点不存在时返回 0 和空的结果
+func (c *consistent) SimulateRemove(slot string, samples int) (movedFraction float64, perNodeDelta map[string]int) {
+	return c.simulate(samples, func(nc *consistent) {
+		nc.deleteNode(slot)
+	})
+}
+
+// SimulateAdd 模拟通过 Add 添加节点，与 SimulateRemove 一致，新添加的节点为正数，
+// 节点已经存在时返回 0 和空的结果
+func (c *consistent) SimulateAdd(slot string, samples int) (movedFraction float64, perNodeDelta map[string]int) {
+	return c.simulate(samples, func(nc *consistent) {
+		nc.addNode(slot, nc.member(slot))
+	})
+}
+
+// simulate 在圆环的副本上执行 change，比较修改前后样本 key 的分布
+func (c *consistent) simulate(samples int, change func(nc *consistent)) (float64, map[string]int) {
+	c.rlock()
+	old := c.clone()
+	c.RUnlock()
+	nc := old.clone()
+	change(nc)
+
+	before, after := old.Distribution(samples), nc.Distribution(samples)
+	delta := make(map[string]int)
+	for node, count := range after {
+		if d := count - before[node]; d != 0 {
+			delta[node] = d
+		}
+	}
+	for node, count := range before {
+		if _, ok := after[node]; !ok && count != 0 {
+			delta[node] = -count
+		}
+	}
+	return MovedFraction(old, nc, samples), delta
+}
+
+// Migration 表示一个 key 从 From 节点迁移到 To 节点
+type Migration struct {
+	Key, From, To string
+}
+
+// DrainNode 删除节点，并返回 keys 中原来属于该节点的 key 的迁移计划
+// 先在圆环的副本上删除节点计算每个 key 新的节点，再从当前圆环中删除该节点，两步在同一个写锁内完成，
+// 返回的结果与 keys 中的顺序一致，不属于该节点的 key 不会出现在结果中，节点不存在时返回 nil 并且不做任何修改
+func (c *consistent) DrainNode(slot string, keys []string) []Migration {
+	c.deletes.Add(1)
+	plan, ok := c.drain(slot, keys)
+	if !ok {
+		return nil
+	}
+	c.notifyRemove(slot)
+	return plan
+}
+
+func (c *consistent) drain(slot string, keys []string) ([]Migration, bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.writable()
+	if _, ok := c.nodes[slot]; !ok {
+		return nil, false
+	}
+	c.settle()
+	old := c.load()
+	nc := c.clone()
+	nc.delete(slot)
+	nc.publish()
+
+	var plan []Migration
+	for _, key := range keys {
+		if from, _ := old.lookup(key, c.hash); from == slot {
+			to, _ := nc.load().lookup(key, c.hash)
+			plan = append(plan, Migration{Key: key, From: from, To: to})
+		}
+	}
+	c.delete(slot)
+	c.publish()
+	return plan, true
+}
+
+// DeleteWithImpact 删除节点，并返回发生迁移的哈希值范围占整个圆环的比例，也就是该节点删除之前负责的比例
+// 在同一个写锁内根据副本的位置计算，不需要对 key 进行采样，N 个节点均匀分布时约为 1/N，
+// 适合在自动缩容时记录影响范围，节点不存在时不做任何处理并返回 0
+func (c *consistent) DeleteWithImpact(slot string) float64 {
+	c.deletes.Add(1)
+	impact, ok := c.deleteImpact(slot)
+	if !ok {
+		return 0
+	}
+	c.notifyRemove(slot)
+	return impact
+}
+
+func (c *consistent) deleteImpact(slot string) (float64, bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.writable()
+	m, ok := c.nodes[slot]
+	if !ok {
+		return 0, false
+	}
+	c.settle()
+	impact := c.owned(m)
+	c.delete(slot)
+	c.publish()
+	return impact, true
+}
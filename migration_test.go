@@ -0,0 +1,154 @@
+package consistent
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMovedKeys(t *testing.T) {
+	old := New()
+	old.Add("192.168.0.1")
+	old.Add("192.168.0.2")
+	new := old.(*consistent).Clone()
+	new.Add("192.168.0.3")
+
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	moved := MovedKeys(old, new, keys)
+	for _, key := range keys {
+		owners, ok := moved[key]
+		if ok != (old.Get(key) != new.Get(key)) {
+			t.Fatalf("MovedKeys reports %q moved = %v", key, ok)
+		}
+		// 新增节点时，只会有 key 迁移到新的节点上
+		if ok && (owners[0] != old.Get(key) || owners[1] != "192.168.0.3") {
+			t.Fatalf("MovedKeys[%q] = %v", key, owners)
+		}
+	}
+}
+
+func TestMovedFraction(t *testing.T) {
+	old := New(WithMurmur3(), WithReplicas(200))
+	for i := 0; i < 10; i++ {
+		old.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	new := old.(*consistent).Clone()
+	new.Add("192.168.0.10")
+
+	fraction := MovedFraction(old, new, 100000)
+	t.Logf("moved fraction: %.4f", fraction)
+	if want := 1.0 / 11; fraction < want*0.7 || fraction > want*1.3 {
+		t.Fatalf("MovedFraction = %.4f, want about %.4f", fraction, want)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	const samples = 10000
+	// 模拟添加的节点与 Add 一样使用 WithReplicasPerNode 指定的副本数量
+	c := New(WithReplicasPerNode(map[string]int{"192.168.0.9": 500})).(*consistent)
+	for i := 0; i < 5; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+
+	tests := []struct {
+		name     string
+		simulate func() (float64, map[string]int)
+		apply    func()
+	}{
+		{
+			"remove",
+			func() (float64, map[string]int) { return c.SimulateRemove("192.168.0.0", samples) },
+			func() { c.Delete("192.168.0.0") },
+		},
+		{
+			"add",
+			func() (float64, map[string]int) { return c.SimulateAdd("192.168.0.9", samples) },
+			func() { c.Add("192.168.0.9") },
+		},
+	}
+	for _, tt := range tests {
+		before := c.Distribution(samples)
+		old := c.Clone()
+		moved, delta := tt.simulate()
+		if !reflect.DeepEqual(c.Distribution(samples), before) {
+			t.Fatalf("%s: simulation modified the ring", tt.name)
+		}
+
+		tt.apply()
+		if want := MovedFraction(old, c, samples); moved != want {
+			t.Fatalf("%s: simulated moved fraction %v, want %v", tt.name, moved, want)
+		}
+		after := c.Distribution(samples)
+		for node := range c.nodes {
+			if after[node]-before[node] != delta[node] {
+				t.Fatalf("%s: simulated delta of %s is %d, want %d", tt.name, node, delta[node], after[node]-before[node])
+			}
+		}
+		t.Logf("%s: moved %.4f, delta %v", tt.name, moved, delta)
+	}
+	if moved, delta := c.SimulateRemove("10.0.0.1", samples); moved != 0 || len(delta) != 0 {
+		t.Fatalf("SimulateRemove of absent node = %v, %v", moved, delta)
+	}
+}
+
+func TestDrainNode(t *testing.T) {
+	c := New().(*consistent)
+	for i := 0; i < 5; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	keys := make([]string, 1000)
+	owned := 0
+	for i := range keys {
+		keys[i] = sampleKey(i)
+		if c.Get(keys[i]) == "192.168.0.0" {
+			owned++
+		}
+	}
+
+	plan := c.DrainNode("192.168.0.0", keys)
+	if c.Contains("192.168.0.0") {
+		t.Fatal("DrainNode did not remove the node")
+	}
+	if len(plan) != owned {
+		t.Fatalf("DrainNode returned %d migrations, want %d", len(plan), owned)
+	}
+	for _, m := range plan {
+		if m.From != "192.168.0.0" || m.To != c.Get(m.Key) {
+			t.Fatalf("migration %+v, want from 192.168.0.0 to %s", m, c.Get(m.Key))
+		}
+	}
+	if plan := c.DrainNode("192.168.0.0", keys); plan != nil {
+		t.Fatalf("DrainNode of absent node = %v, want nil", plan)
+	}
+}
+
+func TestDeleteWithImpact(t *testing.T) {
+	const n = 10
+	c := New(WithReplicas(200), WithHash(md5Hash)).(*consistent)
+	for i := 0; i < n; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	old := c.Clone()
+	want := c.LoadFactor("192.168.0.3") / n
+	impact := c.DeleteWithImpact("192.168.0.3")
+	t.Logf("impact: %.4f, sampled: %.4f", impact, MovedFraction(old, c, 100000))
+	if impact != want {
+		t.Fatalf("DeleteWithImpact = %v, want the former share %v", impact, want)
+	}
+	if math.Abs(impact-1.0/n) > 0.02 {
+		t.Fatalf("DeleteWithImpact = %.4f, want about %.4f", impact, 1.0/n)
+	}
+	if c.Contains("192.168.0.3") || c.Len() != n-1 {
+		t.Fatal("DeleteWithImpact did not delete the node")
+	}
+	if impact := c.DeleteWithImpact("192.168.0.3"); impact != 0 {
+		t.Fatalf("DeleteWithImpact of absent node = %v, want 0", impact)
+	}
+	if impact := c.DeleteWithImpact("192.168.0.0"); math.Abs(impact-1.0/(n-1)) > 0.03 {
+		t.Fatalf("DeleteWithImpact after one removal = %.4f, want about %.4f", impact, 1.0/(n-1))
+	}
+}
@@ -0,0 +1,112 @@
+package consistent
+
+import "context"
+
+// multiProbe 为多探测一致性哈希 (multi-probe consistent hashing) 的实现
+// 每个节点在圆环上只有一个位置，查找时对 key 计算 probes 个哈希值，
+// 选择顺时针距离最近的节点，使用远少于虚拟节点的内存得到相近的均衡性
+type multiProbe struct {
+	// 每个 key 计算的哈希值数量
+	probes int
+	// 每个节点只有一个副本的圆环
+	c *consistent
+}
+
+// NewMultiProbe 创建新的多探测一致性哈希实例，probes 不为正数时使用 1
+// 支持 New 的所有参数选项，但是副本数量固定为 1，WithShards 会被忽略，权重为 w 的节点在圆环上拥有 w 个位置，
+// 探测次数越多分布越均匀，Get 的时间复杂度为 O(probes * log(节点数量))，论文中推荐 21 次
+func NewMultiProbe(probes int, options ...Option) ConsistentHasher {
+	if probes <= 0 {
+		probes = 1
+	}
+	return &multiProbe{
+		probes: probes,
+		c:      New(append(options[:len(options):len(options)], WithReplicas(1), WithShards(1))...).(*consistent),
+	}
+}
+
+// Add 添加一个节点，如果节点已经存在，不做任何处理并返回 false
+func (m *multiProbe) Add(slot string) bool {
+	return m.c.Add(slot)
+}
+
+// Delete 删除一个节点
+func (m *multiProbe) Delete(slot string) {
+	m.c.Delete(slot)
+}
+
+// Get 获取距离 key 的所有探测位置最近的节点，如果没有任何节点，返回空字符串
+func (m *multiProbe) Get(key string) string {
+	server, _ := m.GetCtx(context.Background(), key)
+	return server
+}
+
+// GetCtx 与 Get 一致，每次探测之前检查 ctx，ctx 取消之后返回 ctx.Err()
+func (m *multiProbe) GetCtx(ctx context.Context, key string) (string, error) {
+	m.c.gets.Add(1)
+	r, pos, err := m.probe(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	server, _ := r.get(pos)
+	return server, nil
+}
+
+// GetN 从距离最近的探测位置开始顺时针遍历，获取 n 个不同的节点，第一个元素与 Get 的结果一致
+func (m *multiProbe) GetN(key string, n int) []string {
+	m.c.gets.Add(1)
+	r, pos, _ := m.probe(context.Background(), key)
+	if n <= 0 || r.circle.Len() == 0 {
+		return nil
+	}
+	if n > r.nodes {
+		n = r.nodes
+	}
+	res := make([]string, 0, n)
+	r.walk(pos, func(server string) bool {
+		res = append(res, server)
+		return len(res) < n
+	})
+	return res
+}
+
+// Contains 判断节点是否存在
+func (m *multiProbe) Contains(slot string) bool {
+	return m.c.Contains(slot)
+}
+
+// Len 获取节点的数量
+func (m *multiProbe) Len() int {
+	return m.c.Len()
+}
+
+// Close 释放底层圆环的资源
+func (m *multiProbe) Close() error {
+	return m.c.Close()
+}
+
+// Members 获取到所有的节点，按照名称排序
+func (m *multiProbe) Members() []string {
+	return m.c.Members()
+}
+
+// probe 计算 key 的所有探测位置，返回当前圆环的快照以及顺时针距离节点最近的探测位置
+func (m *multiProbe) probe(ctx context.Context, key string) (*ring, uint64, error) {
+	r := m.c.load()
+	if r.circle.Len() == 0 {
+		return r, 0, nil
+	}
+	var best, distance uint64
+	for i := 0; i < m.probes; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		pos := m.c.hashKey(key, i)
+		// 超过最后一个位置时绕回到 circle[0]，按照哈希值的位数取模
+		d := (r.circle[r.circle.search(pos)] - pos) & m.c.maxPos
+		if i == 0 || d < distance {
+			best, distance = pos, d
+		}
+	}
+	return r, best, nil
+}
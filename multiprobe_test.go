@@ -0,0 +1,62 @@
+package consistent
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMultiProbe(t *testing.T) {
+	m := NewMultiProbe(21).(*multiProbe)
+	if server := m.Get("key"); server != "" {
+		t.Fatalf("Get on empty multi-probe = %q, want empty", server)
+	}
+	ring := New().(*consistent)
+	for i := 0; i < 10; i++ {
+		ip := fmt.Sprintf("192.168.0.%d", i)
+		if !m.Add(ip) {
+			t.Fatalf("Add(%q) returned false", ip)
+		}
+		ring.Add(ip)
+	}
+	if m.Add("192.168.0.0") {
+		t.Fatal("duplicate Add returned true")
+	}
+
+	const samples = 100000
+	statistic := make(map[string]int)
+	for i := 0; i < samples; i++ {
+		key := sampleKey(i)
+		nodes := m.GetN(key, 2)
+		if len(nodes) != 2 || nodes[0] != m.Get(key) || nodes[0] == nodes[1] {
+			t.Fatalf("GetN(%q, 2) = %v, Get = %s", key, nodes, m.Get(key))
+		}
+		statistic[nodes[0]]++
+	}
+	probeDev, ringDev := stdDev(statistic), ring.LoadStdDev(samples)
+	t.Logf("std dev: multi-probe %.4f with %d positions, ring %.4f with %d positions",
+		probeDev, m.c.VirtualNodes(), ringDev, ring.VirtualNodes())
+	if m.c.VirtualNodes() != 10 {
+		t.Fatalf("multi-probe uses %d positions, want 10", m.c.VirtualNodes())
+	}
+	if probeDev > ringDev*1.5 {
+		t.Fatalf("multi-probe std dev %.4f is much worse than ring %.4f", probeDev, ringDev)
+	}
+
+	m.Delete("192.168.0.0")
+	if m.Contains("192.168.0.0") {
+		t.Fatal("Contains returned true after Delete")
+	}
+
+	// WithShards 被忽略，不会因为类型断言失败而 panic
+	if sharded := NewMultiProbe(3, WithShards(2)); !sharded.Add("192.168.0.0") {
+		t.Fatal("Add with WithShards returned false")
+	}
+
+	// 不会修改调用者传入的切片剩余的容量
+	options := make([]Option, 1, 3)
+	options[0] = WithReplicas(5)
+	NewMultiProbe(3, options...)
+	if extra := options[:3]; extra[1] != nil || extra[2] != nil {
+		t.Fatal("NewMultiProbe wrote into the spare capacity of options")
+	}
+}
@@ -0,0 +1,54 @@
+package consistent
+
+import "strconv"
+
+// ShardRing 将 key 映射到固定数量的分区编号 [0, shards)，适合分区数量固定、不需要节点名称的场景
+// 内部使用名称为 "0" 到 "shards-1" 的节点组成的圆环，所以分区数量变化时只有少量的 key 会改变分区，
+// 与 JumpHash 不同，分区数量不需要连续变化，相同的参数选项在不同的进程中得到相同的结果
+type ShardRing struct {
+	// 分区数量
+	shards int
+	// 节点为分区编号的圆环，创建之后不再修改
+	c *consistent
+}
+
+// NewSharded 创建拥有 shards 个分区的 ShardRing，shards 为负数时按 0 处理
+// 支持 WithReplicas, WithHash 等影响圆环布局的参数选项，WithWeights, WithFloatWeights, WithInitialNodes 以及 WithShards 会被忽略，
+// 创建之后不能修改，可以在多个 goroutine 中并发使用
+func NewSharded(shards int, options ...Option) *ShardRing {
+	if shards < 0 {
+		shards = 0
+	}
+	labels := make([]string, shards)
+	for i := range labels {
+		labels[i] = strconv.Itoa(i)
+	}
+	opts := append(options[:len(options):len(options)], WithWeights(nil), WithFloatWeights(nil), WithInitialNodes(labels...), WithShards(1))
+	return &ShardRing{
+		shards: shards,
+		c:      New(opts...).(*consistent),
+	}
+}
+
+// GetShard 获取 key 所在的分区编号，没有任何分区 (或者节点名称不是分区编号) 时返回 -1
+func (s *ShardRing) GetShard(key string) int {
+	server, ok := s.c.GetOK(key)
+	if !ok {
+		return -1
+	}
+	shard, err := strconv.Atoi(server)
+	if err != nil {
+		return -1
+	}
+	return shard
+}
+
+// Get 获取 key 所在的分区编号的字符串形式，没有任何分区时返回空字符串
+func (s *ShardRing) Get(key string) string {
+	return s.c.Get(key)
+}
+
+// Shards 获取分区数量
+func (s *ShardRing) Shards() int {
+	return s.shards
+}
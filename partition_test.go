@@ -0,0 +1,53 @@
+package consistent
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestShardRing(t *testing.T) {
+	if shard := NewSharded(0).GetShard("key"); shard != -1 {
+		t.Fatalf("GetShard on empty ShardRing = %d, want -1", shard)
+	}
+	// WithFloatWeights 添加的节点不是分区编号，会被忽略
+	if s := NewSharded(3, WithFloatWeights(map[string]float64{"node": 1.5})); s.c.Len() != 3 || s.c.Contains("node") {
+		t.Fatalf("ShardRing has members %v, want 0 to 2", s.c.Members())
+	}
+
+	const shards, samples = 10, 10000
+	s := NewSharded(shards, WithShards(4))
+	again := NewSharded(shards, WithShards(4))
+	statistic := make([]int, shards)
+	for i := 0; i < samples; i++ {
+		key := sampleKey(i)
+		shard := s.GetShard(key)
+		if shard < 0 || shard >= shards {
+			t.Fatalf("GetShard(%q) = %d, out of [0, %d)", key, shard, shards)
+		}
+		// 相同的参数得到相同的分区
+		if again.GetShard(key) != shard || s.Get(key) != strconv.Itoa(shard) {
+			t.Fatalf("GetShard(%q) is not stable", key)
+		}
+		statistic[shard]++
+	}
+	t.Log(statistic)
+
+	// 增加一个分区之后只有分配到新分区的 key 发生变化
+	grown := NewSharded(shards + 1)
+	moved := 0
+	for i := 0; i < samples; i++ {
+		key := sampleKey(i)
+		if before, after := s.GetShard(key), grown.GetShard(key); before != after {
+			if after != shards {
+				t.Fatalf("GetShard(%q) moved from %d to existing shard %d", key, before, after)
+			}
+			moved++
+		}
+	}
+	fraction := float64(moved) / samples
+	t.Logf("moved fraction: %.4f", fraction)
+	if math.Abs(fraction-1.0/(shards+1)) > 0.05 {
+		t.Fatalf("moved fraction = %.4f, want about %.4f", fraction, 1.0/(shards+1))
+	}
+}
@@ -0,0 +1,26 @@
+package consistent
+
+// Pin 将 key 固定分配到 node 上，Get, GetOK, GetMany 以及 GetN 的第一个元素会直接返回该节点，不再查找圆环
+// 固定分配在 Add 和 Delete 之后仍然保留，只有 Unpin 和 Reset 会清除；
+// node 不在圆环上时 (例如被删除之后) 暂时按照圆环查找，重新添加之后恢复固定分配，
+// 每次修改都会重新发布圆环的快照，不适合固定大量的 key
+func (c *consistent) Pin(key, node string) {
+	c.Lock()
+	defer c.Unlock()
+	if c.pins == nil {
+		c.pins = make(map[string]string)
+	}
+	c.pins[key] = node
+	c.publish()
+}
+
+// Unpin 取消 key 的固定分配，之后按照圆环查找，key 没有被固定时不做任何处理
+func (c *consistent) Unpin(key string) {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.pins[key]; !ok {
+		return
+	}
+	delete(c.pins, key)
+	c.publish()
+}
@@ -0,0 +1,88 @@
+package consistent
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPin(t *testing.T) {
+	c := New().(*consistent)
+	c.AddBatch("192.168.0.1", "192.168.0.2", "192.168.0.3")
+	// 找到一个不属于 192.168.0.1 的 key 固定到 192.168.0.1 上
+	var key string
+	for i := 0; ; i++ {
+		if key = sampleKey(i); c.Get(key) != "192.168.0.1" {
+			break
+		}
+	}
+	c.Pin(key, "192.168.0.1")
+	check := func(want string) {
+		t.Helper()
+		if server := c.Get(key); server != want {
+			t.Fatalf("Get(%q) = %s, want %s", key, server, want)
+		}
+		if nodes := c.GetN(key, 2); len(nodes) != 2 || nodes[0] != want || nodes[1] == want {
+			t.Fatalf("GetN(%q, 2) = %v, want %s first", key, nodes, want)
+		}
+		if server := c.GetMany([]string{key})[0]; server != want {
+			t.Fatalf("GetMany(%q) = %s, want %s", key, server, want)
+		}
+		if server, pos := c.GetWithPos(key); server != want || c.load().servers[pos] != want {
+			t.Fatalf("GetWithPos(%q) = %s, %d, want %s", key, server, pos, want)
+		}
+		nodes := c.GetNFiltered(key, 2, func(node string) string { return node })
+		if len(nodes) != 2 || nodes[0] != want || nodes[1] == want {
+			t.Fatalf("GetNFiltered(%q, 2) = %v, want %s first", key, nodes, want)
+		}
+		var walked []string
+		c.WalkFrom(key, func(node string) bool {
+			walked = append(walked, node)
+			return true
+		})
+		if len(walked) != c.Len() || walked[0] != want {
+			t.Fatalf("WalkFrom(%q) = %v, want %s first", key, walked, want)
+		}
+	}
+	check("192.168.0.1")
+
+	// 修改圆环之后固定的 key 不变，其他的 key 仍然按照圆环查找
+	for i := 0; i < 10; i++ {
+		c.Add(fmt.Sprintf("10.0.0.%d", i))
+	}
+	check("192.168.0.1")
+	ring := func(key string) string {
+		server, _ := c.load().get(c.hash(key))
+		return server
+	}
+	if other := sampleKey(100000); c.Get(other) != ring(other) {
+		t.Fatal("unpinned key does not follow the ring")
+	}
+
+	// 节点被删除时按照圆环查找，重新添加之后恢复
+	c.Delete("192.168.0.1")
+	if server := c.Get(key); server == "192.168.0.1" {
+		t.Fatal("Get returned a deleted pinned node")
+	}
+	c.Add("192.168.0.1")
+	check("192.168.0.1")
+
+	// Rename 之后固定到新的名称上
+	c.Rename("192.168.0.1", "host-1")
+	check("host-1")
+	c.Rename("host-1", "192.168.0.1")
+	check("192.168.0.1")
+
+	c.Unpin(key)
+	if server := c.Get(key); server != ring(key) {
+		t.Fatalf("Get(%q) after Unpin = %s, want %s", key, server, ring(key))
+	}
+	c.Pin(key, "192.168.0.2")
+	c.Reset()
+	if len(c.pins) != 0 {
+		t.Fatalf("pins after Reset = %v", c.pins)
+	}
+	c.AddBatch("192.168.0.1", "192.168.0.2")
+	if server := c.Get(key); server != ring(key) {
+		t.Fatalf("Get(%q) after Reset = %s, want %s", key, server, ring(key))
+	}
+}
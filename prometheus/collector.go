@@ -0,0 +1,67 @@
+// Package prometheus 将一致性哈希圆环的统计信息导出为 Prometheus 指标
+// 作为单独的模块，不使用该包时不需要引入 Prometheus 的依赖
+package prometheus
+
+import (
+	"github.com/junhaideng/consistent"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// virtualNoder 为支持获取副本数量的实现，例如 consistent.New 创建的圆环
+type virtualNoder interface {
+	VirtualNodes() int
+}
+
+// loadFactorer 为支持获取每个节点负载系数的实现，例如 consistent.New 创建的圆环
+type loadFactorer interface {
+	Members() []string
+	LoadFactor(node string) float64
+}
+
+// collector 在每次采集时读取圆环的统计信息
+type collector struct {
+	hasher     consistent.ConsistentHasher
+	nodes      *prometheus.Desc
+	virtual    *prometheus.Desc
+	loadFactor *prometheus.Desc
+}
+
+// NewCollector 创建导出圆环统计信息的 prometheus.Collector，labels 会作为所有指标的固定标签
+// 导出的指标为 consistent_nodes (节点数量)、consistent_virtual_nodes (副本数量)
+// 以及 consistent_load_factor (每个节点的负载系数，标签 node 为节点名称)，
+// 后两个指标只有实现了对应方法的圆环才会导出；每次采集时调用这些方法，
+// 每个方法都在圆环的读锁内读取，但是不同的方法之间圆环可能发生变化
+func NewCollector(h consistent.ConsistentHasher, labels ...prometheus.Labels) prometheus.Collector {
+	constLabels := prometheus.Labels{}
+	for _, l := range labels {
+		for k, v := range l {
+			constLabels[k] = v
+		}
+	}
+	return &collector{
+		hasher:     h,
+		nodes:      prometheus.NewDesc("consistent_nodes", "Number of physical nodes on the ring.", nil, constLabels),
+		virtual:    prometheus.NewDesc("consistent_virtual_nodes", "Number of virtual node positions on the ring.", nil, constLabels),
+		loadFactor: prometheus.NewDesc("consistent_load_factor", "Share of the ring owned by a node relative to an even split.", []string{"node"}, constLabels),
+	}
+}
+
+// Describe 实现 prometheus.Collector 接口
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.nodes
+	ch <- c.virtual
+	ch <- c.loadFactor
+}
+
+// Collect 实现 prometheus.Collector 接口
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.nodes, prometheus.GaugeValue, float64(c.hasher.Len()))
+	if h, ok := c.hasher.(virtualNoder); ok {
+		ch <- prometheus.MustNewConstMetric(c.virtual, prometheus.GaugeValue, float64(h.VirtualNodes()))
+	}
+	if h, ok := c.hasher.(loadFactorer); ok {
+		for _, node := range h.Members() {
+			ch <- prometheus.MustNewConstMetric(c.loadFactor, prometheus.GaugeValue, h.LoadFactor(node), node)
+		}
+	}
+}
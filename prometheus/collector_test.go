@@ -0,0 +1,45 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/junhaideng/consistent"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	c := consistent.New(consistent.WithReplicas(10))
+	c.Add("192.168.0.1")
+	collector := NewCollector(c, prometheus.Labels{"ring": "cache"})
+
+	// 只有一个节点时负责整个圆环，负载系数为 1
+	want := `
+# HELP consistent_load_factor Share of the ring owned by a node relative to an even split.
+# TYPE consistent_load_factor gauge
+consistent_load_factor{node="192.168.0.1",ring="cache"} 1
+# HELP consistent_nodes Number of physical nodes on the ring.
+# TYPE consistent_nodes gauge
+consistent_nodes{ring="cache"} 1
+# HELP consistent_virtual_nodes Number of virtual node positions on the ring.
+# TYPE consistent_virtual_nodes gauge
+consistent_virtual_nodes{ring="cache"} 10
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Add("192.168.0.2")
+	if n := testutil.CollectAndCount(collector, "consistent_load_factor"); n != 2 {
+		t.Fatalf("collected %d load factor metrics, want 2", n)
+	}
+	want = `
+# HELP consistent_nodes Number of physical nodes on the ring.
+# TYPE consistent_nodes gauge
+consistent_nodes{ring="cache"} 2
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want), "consistent_nodes"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,32 @@
+package consistent
+
+import "errors"
+
+// ErrReadOnly 修改只读的圆环
+var ErrReadOnly = errors.New("consistent: ring is read-only")
+
+// WithReadOnly 创建只读的圆环，只包含 WithWeights, WithInitialNodes 等参数选项中的节点，等同于在 New 之后调用 Freeze
+func WithReadOnly() Option {
+	return func(c *consistent) {
+		c.readOnly = true
+	}
+}
+
+// Freeze 将圆环设置为只读，之后 Add, Delete, Rename, Reset 等修改节点的操作会 panic ErrReadOnly，
+// 返回 error 的 SetReplicas, UnmarshalBinary 以及 UnmarshalJSON 返回 ErrReadOnly，
+// Get 等读操作不受影响，仍然不需要加锁；Pin, MarkDown 以及 GetBounded 不修改节点，仍然可以使用，
+// 只读之后不能恢复，Clone 得到的实例不是只读的
+func (c *consistent) Freeze() {
+	c.Lock()
+	defer c.Unlock()
+	c.readOnly = true
+	// 完成延迟的排序，之后的读操作不会再加锁
+	c.settle()
+}
+
+// writable 只读的圆环 panic ErrReadOnly，必须在写锁内调用
+func (c *consistent) writable() {
+	if c.readOnly {
+		panic(ErrReadOnly)
+	}
+}
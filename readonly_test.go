@@ -0,0 +1,71 @@
+package consistent
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	mustPanic := func(name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if err := recover(); err != ErrReadOnly {
+				t.Fatalf("%s on a frozen ring: recover() = %v, want ErrReadOnly", name, err)
+			}
+		}()
+		fn()
+	}
+
+	c := New(WithLazySort()).(*consistent)
+	c.AddBatch("192.168.0.1", "192.168.0.2", "192.168.0.3")
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	c.Freeze()
+	if c.dirty.Load() {
+		t.Fatal("Freeze did not finish the lazy sort")
+	}
+	want := c.Get("key")
+
+	mustPanic("Add", func() { c.Add("192.168.0.4") })
+	mustPanic("AddWeight", func() { c.AddWeight("192.168.0.4", 2) })
+	mustPanic("Delete", func() { c.Delete("192.168.0.1") })
+	mustPanic("DeleteBatch", func() { c.DeleteBatch("192.168.0.1") })
+	mustPanic("DeleteWhere", func() { c.DeleteWhere(func(string) bool { return true }) })
+	mustPanic("Rename", func() { c.Rename("192.168.0.1", "10.0.0.1") })
+	mustPanic("Reset", func() { c.Reset() })
+	if err := c.SetReplicas(10); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("SetReplicas = %v, want ErrReadOnly", err)
+	}
+	if err := c.UnmarshalBinary(buf.Bytes()); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("UnmarshalBinary = %v, want ErrReadOnly", err)
+	}
+
+	// 读操作不受影响
+	if c.Len() != 3 || c.Get("key") != want || len(c.GetN("key", 3)) != 3 {
+		t.Fatal("frozen ring changed or stopped serving reads")
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if !c.Clone().Add("192.168.0.4") {
+		t.Fatal("Add on a clone of a frozen ring returned false")
+	}
+
+	// WithReadOnly 只包含参数选项中的节点
+	r := New(WithReadOnly(), WithInitialNodes("192.168.0.1")).(*consistent)
+	if !r.Contains("192.168.0.1") {
+		t.Fatal("WithReadOnly ring does not contain the initial node")
+	}
+	mustPanic("Add", func() { r.Add("192.168.0.2") })
+	loaded, err := Load(&buf, WithReadOnly())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.Get("key") != want {
+		t.Fatal("loaded read-only ring assigns keys differently")
+	}
+	mustPanic("Delete", func() { loaded.Delete("192.168.0.1") })
+}
@@ -0,0 +1,162 @@
+package consistent
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+)
+
+// rendezvous 为最高随机权重 (HRW) 哈希的实现
+// 对于每一个 key，计算它和所有节点组合之后的哈希值，选择得分最高的节点，
+// 不需要副本，删除节点时只有属于该节点的 key 会重新分配，适合节点数量较少的集群
+type rendezvous struct {
+	// 所有的节点以及对应的权重
+	nodes map[string]int
+	// 采用的hash算法
+	hash Hash64
+	// 哈希值的最大值，由哈希函数的位数决定
+	maxPos uint64
+	sync.RWMutex
+}
+
+// NewRendezvous 创建新的最高随机权重哈希实例
+// 支持 WithHash, WithHash64 以及 WithWeights 参数选项，
+// 权重为 w 的节点分配到的 key 的数量约为权重为 1 的节点的 w 倍，Get 的时间复杂度为 O(节点数量)
+func NewRendezvous(options ...Option) ConsistentHasher {
+	c := config(options...)
+	r := &rendezvous{
+		nodes:  make(map[string]int),
+		hash:   c.hash,
+		maxPos: c.maxPos,
+	}
+	for node, weight := range c.weights {
+		if weight > 0 {
+			r.nodes[node] = weight
+		}
+	}
+	return r
+}
+
+// Add 添加一个节点，如果节点已经存在，不做任何处理并返回 false
+func (r *rendezvous) Add(slot string) bool {
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.nodes[slot]; ok {
+		return false
+	}
+	r.nodes[slot] = 1
+	return true
+}
+
+// Delete 删除一个节点
+func (r *rendezvous) Delete(slot string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.nodes, slot)
+}
+
+// Get 获取得分最高的节点，如果没有任何节点，返回空字符串
+func (r *rendezvous) Get(key string) string {
+	server, _ := r.GetCtx(context.Background(), key)
+	return server
+}
+
+// 遍历节点时每隔多少个节点检查一次 context 是否已经取消
+const ctxCheckInterval = 64
+
+// GetCtx 与 Get 一致，遍历节点的过程中定期检查 ctx，ctx 取消之后返回 ctx.Err()
+func (r *rendezvous) GetCtx(ctx context.Context, key string) (string, error) {
+	r.RLock()
+	defer r.RUnlock()
+	var (
+		best  string
+		score float64
+		i     int
+	)
+	for node := range r.nodes {
+		if i%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+		}
+		i++
+		s := r.score(node, key)
+		// 得分相同时选择名称较小的节点，保证结果是确定的
+		if best == "" || s > score || (s == score && node < best) {
+			best, score = node, s
+		}
+	}
+	return best, nil
+}
+
+// GetN 按照得分从高到低获取 n 个节点，第一个元素与 Get 的结果一致
+func (r *rendezvous) GetN(key string, n int) []string {
+	r.RLock()
+	defer r.RUnlock()
+	if n <= 0 || len(r.nodes) == 0 {
+		return nil
+	}
+	type candidate struct {
+		node  string
+		score float64
+	}
+	candidates := make([]candidate, 0, len(r.nodes))
+	for node := range r.nodes {
+		candidates = append(candidates, candidate{node: node, score: r.score(node, key)})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].node < candidates[j].node
+	})
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	res := make([]string, n)
+	for i := range res {
+		res[i] = candidates[i].node
+	}
+	return res
+}
+
+// Contains 判断节点是否存在
+func (r *rendezvous) Contains(slot string) bool {
+	r.RLock()
+	defer r.RUnlock()
+	_, ok := r.nodes[slot]
+	return ok
+}
+
+// Len 获取节点的数量
+func (r *rendezvous) Len() int {
+	r.RLock()
+	defer r.RUnlock()
+	return len(r.nodes)
+}
+
+// Close 没有需要释放的资源，总是返回 nil
+func (r *rendezvous) Close() error {
+	return nil
+}
+
+// Members 获取到所有的节点，按照名称排序
+func (r *rendezvous) Members() []string {
+	r.RLock()
+	defer r.RUnlock()
+	res := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		res = append(res, node)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// score 计算节点对于 key 的得分
+// 直接将哈希值乘以权重并不能让 key 的数量与权重成正比，这里将哈希值映射成 (0, 1) 之间的均匀分布 u，
+// 得分为 -weight / ln(u)，相当于参数为 weight 的指数分布，每个节点得分最高的概率正好为 weight / 权重之和
+func (r *rendezvous) score(node, key string) float64 {
+	u := (float64(r.hash(node+key)) + 0.5) / (float64(r.maxPos) + 1)
+	return -float64(r.nodes[node]) / math.Log(u)
+}
@@ -0,0 +1,136 @@
+package consistent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRendezvous(t *testing.T) {
+	r := NewRendezvous()
+	if server := r.Get("key"); server != "" {
+		t.Fatalf("Get on empty rendezvous = %q, want empty", server)
+	}
+	ips := []string{"192.168.0.1", "192.168.0.2", "192.168.0.3", "192.168.0.4"}
+	for _, ip := range ips {
+		if !r.Add(ip) {
+			t.Fatalf("Add(%q) returned false", ip)
+		}
+	}
+	if r.Add(ips[0]) {
+		t.Fatal("duplicate Add returned true")
+	}
+
+	statistic := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		nodes := r.GetN(key, 2)
+		if len(nodes) != 2 || nodes[0] != r.Get(key) || nodes[0] == nodes[1] {
+			t.Fatalf("GetN(%q, 2) = %v, Get = %s", key, nodes, r.Get(key))
+		}
+		statistic[nodes[0]]++
+	}
+	t.Log(statistic)
+	if len(statistic) != len(ips) {
+		t.Fatalf("keys are assigned to %d nodes, want %d", len(statistic), len(ips))
+	}
+
+	r.Delete(ips[0])
+	if r.Contains(ips[0]) {
+		t.Fatal("Contains returned true after Delete")
+	}
+}
+
+func TestRendezvousMovement(t *testing.T) {
+	ring, hrw := New(), NewRendezvous()
+	for i := 0; i < 10; i++ {
+		ring.Add(fmt.Sprintf("192.168.0.%d", i))
+		hrw.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+
+	const samples = 10000
+	before := make(map[string][2]string, samples)
+	for i := 0; i < samples; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = [2]string{ring.Get(key), hrw.Get(key)}
+	}
+
+	ring.Delete("192.168.0.0")
+	hrw.Delete("192.168.0.0")
+	ringMoved, hrwMoved := 0, 0
+	for key, owners := range before {
+		if owner := ring.Get(key); owner != owners[0] {
+			if owners[0] != "192.168.0.0" {
+				t.Fatalf("ring moved %q from surviving node %s to %s", key, owners[0], owner)
+			}
+			ringMoved++
+		}
+		if owner := hrw.Get(key); owner != owners[1] {
+			if owners[1] != "192.168.0.0" {
+				t.Fatalf("rendezvous moved %q from surviving node %s to %s", key, owners[1], owner)
+			}
+			hrwMoved++
+		}
+	}
+	t.Logf("moved keys: ring %d, rendezvous %d", ringMoved, hrwMoved)
+}
+
+func TestRendezvousWeights(t *testing.T) {
+	r := NewRendezvous(WithWeights(map[string]int{"192.168.0.1": 1, "192.168.0.2": 2}))
+	if !r.Contains("192.168.0.1") || !r.Contains("192.168.0.2") {
+		t.Fatal("WithWeights nodes are missing")
+	}
+	statistic := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		statistic[r.Get(fmt.Sprintf("key-%d", i))]++
+	}
+	t.Log(statistic)
+	if statistic["192.168.0.2"] <= statistic["192.168.0.1"] {
+		t.Fatalf("weight-2 node received fewer keys than weight-1 node: %v", statistic)
+	}
+}
+
+func TestGetCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	hashers := map[string]interface {
+		ConsistentHasher
+		GetCtx(ctx context.Context, key string) (string, error)
+	}{
+		"ring":        New().(*consistent),
+		"rendezvous":  NewRendezvous().(*rendezvous),
+		"multi-probe": NewMultiProbe(21).(*multiProbe),
+	}
+	for name, h := range hashers {
+		h.Add("192.168.0.1")
+		h.Add("192.168.0.2")
+		if server, err := h.GetCtx(ctx, "key"); !errors.Is(err, context.Canceled) || server != "" {
+			t.Errorf("%s: GetCtx with cancelled context = %q, %v", name, server, err)
+		}
+		if server, err := h.GetCtx(context.Background(), "key"); err != nil || server != h.Get("key") {
+			t.Errorf("%s: GetCtx = %q, %v, want %q", name, server, err, h.Get("key"))
+		}
+	}
+}
+
+func TestRendezvousWeightRatio(t *testing.T) {
+	r := NewRendezvous(WithWeights(map[string]int{"192.168.0.1": 1, "192.168.0.2": 2, "192.168.0.3": 1}))
+	const samples = 100000
+	ratio := func() float64 {
+		statistic := make(map[string]int)
+		for i := 0; i < samples; i++ {
+			statistic[r.Get(sampleKey(i))]++
+		}
+		t.Log(statistic)
+		return float64(statistic["192.168.0.2"]) / float64(statistic["192.168.0.1"])
+	}
+	if got := ratio(); got < 1.9 || got > 2.1 {
+		t.Fatalf("weight-2 node received %.3fx the keys of weight-1 node, want ~2x", got)
+	}
+	// 删除节点之后比例保持不变
+	r.Delete("192.168.0.3")
+	if got := ratio(); got < 1.9 || got > 2.1 {
+		t.Fatalf("after Delete weight-2 node received %.3fx the keys of weight-1 node, want ~2x", got)
+	}
+}
@@ -0,0 +1,148 @@
+package consistent
+
+import "sort"
+
+// WithShards 将圆环划分成 n 个拥有独立锁的子圆环，降低 Add 和 Delete 之间的竞争，n 小于等于 1 时不进行划分
+// key 在子圆环之间均匀分布，与子圆环中节点的数量无关，节点数量较少时不要使用
+func WithShards(n int) Option {
+	return func(c *consistent) {
+		c.shards = n
+	}
+}
+
+// sharded 为划分成多个子圆环的一致性哈希
+type sharded struct {
+	// 所有的子圆环
+	shards []*consistent
+	// 选择子圆环时采用的hash算法
+	hash Hash64
+}
+
+// newSharded 创建 n 个子圆环，WithWeights, WithFloatWeights 和 WithInitialNodes 中的节点添加到各自所属的子圆环中
+func newSharded(c *consistent, n int, options []Option) *sharded {
+	s := &sharded{
+		shards: make([]*consistent, n),
+		hash:   c.hash,
+	}
+	weights := make([]map[string]int, n)
+	for node, weight := range c.weights {
+		i := s.index(node)
+		if weights[i] == nil {
+			weights[i] = make(map[string]int)
+		}
+		weights[i][node] = weight
+	}
+	floatWeights := make([]map[string]float64, n)
+	for node, weight := range c.floatWeights {
+		i := s.index(node)
+		if floatWeights[i] == nil {
+			floatWeights[i] = make(map[string]float64)
+		}
+		floatWeights[i][node] = weight
+	}
+	initial := make([][]string, n)
+	for _, node := range c.initial {
+		i := s.index(node)
+		initial[i] = append(initial[i], node)
+	}
+	for i := range s.shards {
+		opts := append(options[:len(options):len(options)], WithWeights(weights[i]), WithFloatWeights(floatWeights[i]), WithInitialNodes(initial[i]...), WithShards(1), withHashOf(c))
+		s.shards[i] = New(opts...).(*consistent)
+	}
+	return s
+}
+
+// withHashOf 让子圆环直接使用 c 中已经确定的哈希函数，WithHashFactory 和 WithNamespace 已经包含在其中，不再重复处理
+func withHashOf(c *consistent) Option {
+	return func(sub *consistent) {
+		sub.hash, sub.maxPos = c.hash, c.maxPos
+		sub.hashFactory, sub.namespace = nil, ""
+	}
+}
+
+// index 获取节点或者 key 所属的子圆环的编号
+func (s *sharded) index(name string) int {
+	return JumpHash(s.hash(name), len(s.shards))
+}
+
+// shard 获取 key 所属的子圆环，如果该子圆环中没有任何节点，依次使用之后的子圆环，
+// 所有的子圆环都为空时返回 nil
+func (s *sharded) shard(key string) (int, *ring) {
+	i := s.index(key)
+	for j := range s.shards {
+		r := s.shards[(i+j)%len(s.shards)].load()
+		if r.circle.Len() > 0 {
+			return (i + j) % len(s.shards), r
+		}
+	}
+	return 0, nil
+}
+
+// Add 添加一个节点到所属的子圆环中，如果节点已经存在，不做任何处理并返回 false
+func (s *sharded) Add(slot string) bool {
+	return s.shards[s.index(slot)].Add(slot)
+}
+
+// Delete 从所属的子圆环中删除一个节点
+func (s *sharded) Delete(slot string) {
+	s.shards[s.index(slot)].Delete(slot)
+}
+
+// Get 获取 key 在所属的子圆环中的节点，如果没有任何节点，返回空字符串
+func (s *sharded) Get(key string) string {
+	i, r := s.shard(key)
+	if r == nil {
+		return ""
+	}
+	// 计入实际查找的子圆环
+	s.shards[i].gets.Add(1)
+	server, _ := r.get(s.shards[i].hash(key))
+	return server
+}
+
+// GetN 先从 key 所属的子圆环中获取节点，不足 n 个时依次从之后的子圆环中获取，
+// 第一个元素与 Get 的结果一致
+func (s *sharded) GetN(key string, n int) []string {
+	i, r := s.shard(key)
+	if n <= 0 || r == nil {
+		return nil
+	}
+	s.shards[i].gets.Add(1)
+	var res []string
+	for j := 0; j < len(s.shards) && len(res) < n; j++ {
+		res = append(res, s.shards[(i+j)%len(s.shards)].getN(key, n-len(res))...)
+	}
+	return res
+}
+
+// Contains 判断节点是否存在
+func (s *sharded) Contains(slot string) bool {
+	return s.shards[s.index(slot)].Contains(slot)
+}
+
+// Len 获取所有子圆环中节点的数量
+func (s *sharded) Len() int {
+	n := 0
+	for _, shard := range s.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// Close 释放所有子圆环的资源
+func (s *sharded) Close() error {
+	for _, shard := range s.shards {
+		shard.Close()
+	}
+	return nil
+}
+
+// Members 获取到所有子圆环中的节点，按照名称排序
+func (s *sharded) Members() []string {
+	var res []string
+	for _, shard := range s.shards {
+		res = append(res, shard.Members()...)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,74 @@
+package consistent
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestShards(t *testing.T) {
+	s, ok := New(WithShards(4), WithWeights(map[string]int{"192.168.0.1": 1})).(*sharded)
+	if !ok {
+		t.Fatal("New(WithShards(4)) did not return a sharded ring")
+	}
+	if !s.Contains("192.168.0.1") || len(s.Members()) != 1 {
+		t.Fatalf("Members() = %v, want [192.168.0.1]", s.Members())
+	}
+	// 只有一个节点时所有的 key 都分配到该节点，即使所属的子圆环为空
+	for i := 0; i < 100; i++ {
+		if server := s.Get(sampleKey(i)); server != "192.168.0.1" {
+			t.Fatalf("Get(%q) = %q, want 192.168.0.1", sampleKey(i), server)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		s.Add(fmt.Sprintf("nodes-%d", i))
+	}
+	if s.Add("nodes-0") {
+		t.Fatal("duplicate Add returned true")
+	}
+	statistic := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		key := sampleKey(i)
+		nodes := s.GetN(key, 3)
+		if len(nodes) != 3 || nodes[0] != s.Get(key) || nodes[0] == nodes[1] || nodes[1] == nodes[2] {
+			t.Fatalf("GetN(%q, 3) = %v, Get = %s", key, nodes, s.Get(key))
+		}
+		statistic[nodes[0]]++
+	}
+	t.Logf("std dev: %.4f", stdDev(statistic))
+
+	s.Delete("192.168.0.1")
+	if s.Contains("192.168.0.1") {
+		t.Fatal("Contains returned true after Delete")
+	}
+	if New(WithShards(1)).(*consistent) == nil {
+		t.Fatal("New(WithShards(1)) returned nil")
+	}
+}
+
+// 并发添加、删除节点的同时读取圆环，对比不划分和划分成 8 个子圆环
+func BenchmarkShards(b *testing.B) {
+	for _, shards := range []int{1, 8} {
+		b.Run(fmt.Sprintf("shards-%d", shards), func(b *testing.B) {
+			c := New(WithShards(shards))
+			for i := 0; i < 1000; i++ {
+				c.Add(fmt.Sprintf("nodes-%d", i))
+			}
+			var id int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				node := fmt.Sprintf("writer-%d", atomic.AddInt64(&id, 1))
+				for i := 0; pb.Next(); i++ {
+					// 每 4 次操作中有一次修改圆环
+					if i%4 == 0 {
+						c.Add(node)
+						c.Delete(node)
+						continue
+					}
+					c.Get(sampleKey(i))
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package consistent
+
+// RingSnapshot 为圆环在某一时刻的只读快照，所有的方法都不需要加锁
+// 快照创建之后不会再随着圆环变化，添加或者删除节点之后快照就过期了，
+// 仍然会返回旧的节点 (包括已经被删除的节点)，需要重新调用 Snapshot 获取新的快照
+type RingSnapshot struct {
+	r    *ring
+	hash Hash64
+}
+
+// Snapshot 获取当前圆环的只读快照
+// 圆环每次修改之后都会发布一个不可变的副本，这里直接复用该副本，不需要额外的复制
+func (c *consistent) Snapshot() *RingSnapshot {
+	return &RingSnapshot{r: c.load(), hash: c.hash}
+}
+
+// Get 获取到属于的server结点
+// 如果快照中没有任何节点，返回空字符串
+func (s *RingSnapshot) Get(name string) string {
+	server, _ := s.r.lookup(name, s.hash)
+	return server
+}
+
+// Len 获取快照中物理节点的数量
+func (s *RingSnapshot) Len() int {
+	return s.r.nodes
+}
@@ -0,0 +1,71 @@
+package consistent
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	c := New().(*consistent)
+	if server := c.Snapshot().Get("key"); server != "" {
+		t.Fatalf("Get on empty snapshot = %q, want empty", server)
+	}
+	c.AddBatch("192.168.0.1", "192.168.0.2", "192.168.0.3")
+	s := c.Snapshot()
+	if s.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", s.Len())
+	}
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := sampleKey(i)
+		if server := s.Get(key); server != c.Get(key) {
+			t.Fatalf("snapshot Get(%q) = %s, want %s", key, server, c.Get(key))
+		}
+		before[key] = s.Get(key)
+	}
+
+	// 圆环修改之后快照保持不变
+	c.Delete("192.168.0.1")
+	for key, server := range before {
+		if got := s.Get(key); got != server {
+			t.Fatalf("snapshot Get(%q) = %s after Delete, want %s", key, got, server)
+		}
+	}
+	if c.Snapshot().Len() != 2 {
+		t.Fatal("new snapshot does not reflect Delete")
+	}
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	c := New().(*consistent)
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("nodes-%d", i))
+	}
+
+	b.Run("RWMutex", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				c.RLock()
+				_ = c.servers[c.circle[c.circle.search(c.hash("key"))]]
+				c.RUnlock()
+			}
+		})
+	})
+
+	b.Run("Get", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				c.Get("key")
+			}
+		})
+	})
+
+	b.Run("RingSnapshot", func(b *testing.B) {
+		s := c.Snapshot()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				s.Get("key")
+			}
+		})
+	})
+}
@@ -0,0 +1,245 @@
+package consistent
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Distribution 统计 samples 个样本 key 在各个节点上的分布情况
+// 样本 key 为 "key-0", "key-1" ... 这样连续编号的字符串，
+// 返回的结果中包含所有的节点，没有分配到 key 的节点数量为 0
+func (c *consistent) Distribution(samples int) map[string]int {
+	c.rlock()
+	defer c.RUnlock()
+	return c.distribution(samples)
+}
+
+// distribution 统计样本 key 的分布情况，必须在 rlock 之后调用
+func (c *consistent) distribution(samples int) map[string]int {
+	res := make(map[string]int, len(c.nodes))
+	for node := range c.nodes {
+		res[node] = 0
+	}
+	r := c.load()
+	if r.circle.Len() == 0 {
+		return res
+	}
+	for i := 0; i < samples; i++ {
+		server, _ := r.get(c.hash(sampleKey(i)))
+		res[server]++
+	}
+	return res
+}
+
+// OpStats 获取查找的 key 以及添加、删除的节点的数量，用于容量规划
+// 所有查找 key 所属节点的方法 (GetN, WalkFrom, GetHealthy 等) 都计入 gets，GetMany 和 AddBatch 等批量操作中的每个元素各计一次，
+// DeleteWhere 只统计满足条件的节点，
+// 节点已经存在或者不存在的调用同样会被统计，计数器使用原子操作，不需要加锁，Clone 得到的实例从 0 开始计数
+func (c *consistent) OpStats() (gets, adds, deletes uint64) {
+	return c.gets.Load(), c.adds.Load(), c.deletes.Load()
+}
+
+// WeightedDistributionError 返回每个节点实际分配到的 key 的比例相对于按照副本数量期望的比例的误差
+// 误差为 (实际比例 - 期望比例) / 期望比例，用于检查权重是否真正生效
+func (c *consistent) WeightedDistributionError(samples int) map[string]float64 {
+	c.rlock()
+	defer c.RUnlock()
+	res := make(map[string]float64, len(c.nodes))
+	if len(c.nodes) == 0 || samples <= 0 {
+		return res
+	}
+	total := 0
+	for _, m := range c.nodes {
+		total += m.count(c.replicas)
+	}
+	distribution := c.distribution(samples)
+	for node, m := range c.nodes {
+		expected := float64(m.count(c.replicas)) / float64(total)
+		observed := float64(distribution[node]) / float64(samples)
+		res[node] = (observed - expected) / expected
+	}
+	return res
+}
+
+// sampleKey 生成第 i 个样本 key
+func sampleKey(i int) string {
+	return "key-" + strconv.Itoa(i)
+}
+
+// ArcHistogram 将圆环平均划分成 buckets 段，统计 samples 个样本 key 的哈希值落在每一段中的数量
+// 与节点无关，只反映哈希函数本身的聚集情况，例如 fnv 对于相差不多的 key 会集中在少数几段中，
+// buckets 不为正数时返回 nil
+func (c *consistent) ArcHistogram(buckets, samples int) []int {
+	if buckets <= 0 {
+		return nil
+	}
+	res := make([]int, buckets)
+	// 将哈希值左移到 64 位之后乘以 buckets 取高 64 位，得到 hash * buckets / (maxPos + 1)，不会溢出
+	shift := uint(64 - bits.Len64(c.maxPos))
+	for i := 0; i < samples; i++ {
+		bucket, _ := bits.Mul64(c.hash(sampleKey(i))<<shift, uint64(buckets))
+		res[bucket]++
+	}
+	return res
+}
+
+// LoadStdDev 计算各个节点负载的标准差，并且除以平均负载进行归一化
+// 结果越小说明分布越均匀，可以用来选择合适的副本数量
+func (c *consistent) LoadStdDev(samples int) float64 {
+	return stdDev(c.Distribution(samples))
+}
+
+// stdDev 计算归一化之后的标准差
+func stdDev(distribution map[string]int) float64 {
+	if len(distribution) == 0 {
+		return 0
+	}
+	total := 0
+	for _, count := range distribution {
+		total += count
+	}
+	mean := float64(total) / float64(len(distribution))
+	if mean == 0 {
+		return 0
+	}
+	variance := 0.0
+	for _, count := range distribution {
+		d := float64(count) - mean
+		variance += d * d
+	}
+	variance /= float64(len(distribution))
+	return math.Sqrt(variance) / mean
+}
+
+// 副本数量不超过该值时，String 输出每个副本的位置
+const stringPositionsLimit = 64
+
+// String 实现 fmt.Stringer 接口，输出圆环的布局
+// 每一行为一个节点以及它的副本数量，副本总数不超过 64 时还会输出每个副本排序之后的位置
+func (c *consistent) String() string {
+	c.RLock()
+	defer c.RUnlock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "consistent{nodes: %d, virtual nodes: %d, replicas: %d}", len(c.nodes), c.circle.Len(), c.replicas)
+	for _, node := range c.sortedMembers() {
+		positions := c.nodes[node].positions
+		fmt.Fprintf(&b, "\n  %s: %d", node, positions.Len())
+		if c.circle.Len() <= stringPositionsLimit {
+			sorted := append(uints(nil), positions...)
+			sort.Sort(sorted)
+			fmt.Fprintf(&b, " %v", []uint64(sorted))
+		}
+	}
+	return b.String()
+}
+
+// RingStats 为圆环的统计信息
+// 负载为节点负责的哈希值范围占整个圆环的比例，根据副本的位置计算得到，
+// StdDev 为负载的标准差除以平均负载，与 LoadStdDev 的含义一致
+type RingStats struct {
+	// 物理节点和副本的数量
+	Nodes, VirtualNodes int
+	// 节点负载的最小值、最大值以及平均值
+	MinLoad, MaxLoad, MeanLoad float64
+	// 归一化之后的负载标准差
+	StdDev float64
+	// 因为哈希冲突而被丢弃的副本数量
+	Collisions int
+}
+
+// Stats 获取圆环的统计信息，圆环为空时所有的字段都为 0
+func (c *consistent) Stats() RingStats {
+	c.rlock()
+	defer c.RUnlock()
+	stats := RingStats{Nodes: len(c.nodes), VirtualNodes: c.circle.Len()}
+	if stats.VirtualNodes == 0 {
+		return stats
+	}
+	loads := make([]float64, 0, len(c.nodes))
+	for _, m := range c.nodes {
+		stats.Collisions += m.count(c.replicas) - len(m.positions)
+		load := c.owned(m)
+		if len(loads) == 0 || load < stats.MinLoad {
+			stats.MinLoad = load
+		}
+		if load > stats.MaxLoad {
+			stats.MaxLoad = load
+		}
+		loads = append(loads, load)
+	}
+	stats.MeanLoad = 1 / float64(len(loads))
+	variance := 0.0
+	for _, load := range loads {
+		d := load - stats.MeanLoad
+		variance += d * d
+	}
+	stats.StdDev = math.Sqrt(variance/float64(len(loads))) / stats.MeanLoad
+	return stats
+}
+
+// nodeStats 为 StatsJSON 中每个节点的统计信息
+type nodeStats struct {
+	Node         string  `json:"node"`
+	LoadFactor   float64 `json:"load_factor"`
+	VirtualNodes int     `json:"virtual_nodes"`
+}
+
+// StatsJSON 获取便于调试页面 (例如 /debug/ring) 展示的 JSON 格式的统计信息，
+// 包括按照名称排序的所有节点的 LoadFactor 和副本数量，以及 LoadFactor 的标准差 std_dev，
+// 在读锁内根据副本的位置计算，不需要对 key 进行采样，与 MarshalJSON 不同，结果不能用来恢复圆环
+func (c *consistent) StatsJSON() ([]byte, error) {
+	c.rlock()
+	defer c.RUnlock()
+	res := struct {
+		Nodes  []nodeStats `json:"nodes"`
+		StdDev float64     `json:"std_dev"`
+	}{Nodes: make([]nodeStats, 0, len(c.nodes))}
+	if c.circle.Len() > 0 {
+		variance := 0.0
+		for _, node := range c.sortedMembers() {
+			m := c.nodes[node]
+			f := c.owned(m) * float64(len(c.nodes))
+			res.Nodes = append(res.Nodes, nodeStats{Node: node, LoadFactor: f, VirtualNodes: len(m.positions)})
+			variance += (f - 1) * (f - 1)
+		}
+		res.StdDev = math.Sqrt(variance / float64(len(c.nodes)))
+	}
+	return json.Marshal(res)
+}
+
+// Collision 表示圆环上被多个副本同时计算得到的位置
+type Collision struct {
+	Pos uint64
+	// 计算得到该位置的副本所属的节点，按照名称排序，同一个节点的多个副本冲突时会重复出现
+	Nodes []string
+}
+
+// CollisionReport 根据所有的节点重新计算每个副本在没有冲突时的位置，返回被多个副本计算得到的位置，按照位置排序
+// servers 中每个位置只保留一个节点，冲突的副本会重新探测或者被丢弃，无法从圆环中看出，
+// 这里直接统计哈希函数本身的冲突，用于判断哈希函数 (例如 fnv) 的冲突是否真的影响当前的节点，没有冲突时返回 nil
+func (c *consistent) CollisionReport() []Collision {
+	c.rlock()
+	defer c.RUnlock()
+	claims := make(map[uint64][]string, c.circle.Len())
+	for _, node := range c.sortedMembers() {
+		m := c.nodes[node]
+		id := m.key(node)
+		for i := 0; i < m.count(c.replicas); i++ {
+			pos := c.initialPosition(id, i)
+			claims[pos] = append(claims[pos], node)
+		}
+	}
+	var res []Collision
+	for pos, nodes := range claims {
+		if len(nodes) > 1 {
+			res = append(res, Collision{Pos: pos, Nodes: nodes})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Pos < res[j].Pos })
+	return res
+}
@@ -0,0 +1,289 @@
+package consistent
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDistribution(t *testing.T) {
+	c := New().(*consistent)
+	c.Add("192.168.0.1")
+	c.Add("192.168.0.2")
+
+	distribution := c.Distribution(1000)
+	if len(distribution) != 2 {
+		t.Fatalf("Distribution returned %d nodes, want 2", len(distribution))
+	}
+	if total := distribution["192.168.0.1"] + distribution["192.168.0.2"]; total != 1000 {
+		t.Fatalf("Distribution assigned %d keys, want 1000", total)
+	}
+}
+
+func TestLoadStdDev(t *testing.T) {
+	prev := 0.0
+	for i, replicas := range []int{1, 10, 100, 1000} {
+		c := New(WithHash(md5Hash), WithReplicas(replicas)).(*consistent)
+		for j := 0; j < 10; j++ {
+			c.Add(fmt.Sprintf("192.168.0.%d", j))
+		}
+		stddev := c.LoadStdDev(100000)
+		t.Logf("replicas: %d, stddev: %.4f", replicas, stddev)
+		if i > 0 && stddev >= prev {
+			t.Fatalf("stddev with %d replicas = %.4f, not lower than %.4f", replicas, stddev, prev)
+		}
+		prev = stddev
+	}
+}
+
+func TestString(t *testing.T) {
+	c := New(WithReplicas(2)).(*consistent)
+	c.AddBatch("192.168.0.1", "192.168.0.2")
+	s := c.String()
+	t.Log(s)
+	for _, node := range c.Members() {
+		if !strings.Contains(s, node) {
+			t.Fatalf("String() does not contain %s", node)
+		}
+	}
+	for _, pos := range c.circle {
+		if !strings.Contains(s, strconv.FormatUint(pos, 10)) {
+			t.Fatalf("String() does not contain position %d", pos)
+		}
+	}
+
+	// 副本较多时只输出数量
+	c.SetReplicas(100)
+	if s := c.String(); strings.Contains(s, "[") || !strings.Contains(s, "192.168.0.1: 100") {
+		t.Fatalf("String() for a large ring = %s", s)
+	}
+}
+
+func TestStats(t *testing.T) {
+	if stats := New().(*consistent).Stats(); stats != (RingStats{}) {
+		t.Fatalf("Stats on empty ring = %+v", stats)
+	}
+	// 只有 64 个可用的位置，大部分副本都会因为冲突被丢弃
+	for _, c := range []*consistent{
+		New().(*consistent),
+		New(WithHash(func(name string) uint32 { return fnv32(name) % 64 })).(*consistent),
+	} {
+		for i := 0; i < 10; i++ {
+			c.Add(fmt.Sprintf("192.168.0.%d", i))
+		}
+		stats := c.Stats()
+		t.Logf("%+v", stats)
+		if stats.Nodes != 10 || stats.VirtualNodes != c.VirtualNodes() {
+			t.Fatalf("Stats() = %+v, want 10 nodes and %d virtual nodes", stats, c.VirtualNodes())
+		}
+		if stats.VirtualNodes+stats.Collisions != stats.Nodes*c.replicas {
+			t.Fatalf("VirtualNodes + Collisions = %d, want %d", stats.VirtualNodes+stats.Collisions, stats.Nodes*c.replicas)
+		}
+		if stats.MinLoad > stats.MeanLoad || stats.MeanLoad > stats.MaxLoad || stats.MeanLoad != 0.1 {
+			t.Fatalf("loads are inconsistent: %+v", stats)
+		}
+		if f := c.LoadFactor("192.168.0.0"); f < stats.MinLoad/stats.MeanLoad || f > stats.MaxLoad/stats.MeanLoad {
+			t.Fatalf("LoadFactor = %v is outside [MinLoad, MaxLoad] / MeanLoad", f)
+		}
+	}
+}
+
+func TestOpStats(t *testing.T) {
+	c := New().(*consistent)
+	for i := 0; i < 5; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	// 已经存在的节点同样会被统计
+	c.Add("192.168.0.0")
+	for i := 0; i < 100; i++ {
+		c.Get(sampleKey(i))
+	}
+	c.GetOK("key")
+	c.Delete("192.168.0.1")
+	c.DeleteOK("192.168.0.9")
+	if gets, adds, deletes := c.OpStats(); gets != 101 || adds != 6 || deletes != 2 {
+		t.Fatalf("OpStats() = %d, %d, %d, want 101, 6, 2", gets, adds, deletes)
+	}
+
+	// 批量操作中的每个 key 和节点各计一次
+	c.GetMany([]string{"a", "b", "c"})
+	c.GetBytes([]byte("key"))
+	c.AddBatch("10.0.0.1", "10.0.0.2")
+	c.AddWeight("10.0.0.3", 2)
+	c.AddWithReplicas("10.0.0.4", 10)
+	c.AddWeightFloat("10.0.0.5", 1.5)
+	c.AddReturningPositions("10.0.0.6")
+	c.DeleteBatch("10.0.0.1", "10.0.0.9")
+	c.DeleteWhere(func(slot string) bool { return slot == "10.0.0.2" || slot == "10.0.0.3" })
+	c.DrainNode("10.0.0.4", nil)
+	if gets, adds, deletes := c.OpStats(); gets != 105 || adds != 12 || deletes != 7 {
+		t.Fatalf("OpStats() = %d, %d, %d, want 105, 12, 7", gets, adds, deletes)
+	}
+	if gets, adds, deletes := c.Clone().(*consistent).OpStats(); gets != 0 || adds != 0 || deletes != 0 {
+		t.Fatalf("OpStats() on clone = %d, %d, %d, want zeros", gets, adds, deletes)
+	}
+
+	// 其他查找节点的方法同样计入 gets，GetTwo 和 Successor 只计一次
+	c = New(WithInitialNodes("192.168.0.1", "192.168.0.2", "192.168.0.3")).(*consistent)
+	c.GroupByNode([]string{"a", "b"})
+	c.GetN("key", 2)
+	c.GetNFiltered("key", 2, func(node string) string { return node })
+	c.GetTwo("key")
+	c.WalkFrom("key", func(string) bool { return false })
+	c.GetWithPos("key")
+	c.GetHealthy("key")
+	c.GetReplica("key")
+	c.Neighbors("key")
+	c.ClosestNodes("key", 2)
+	c.Predecessor("key", true)
+	c.Successor("key")
+	if gets, _, _ := c.OpStats(); gets != 13 {
+		t.Fatalf("gets = %d, want 13", gets)
+	}
+
+	m := NewMultiProbe(3, WithInitialNodes("192.168.0.1")).(*multiProbe)
+	m.Get("key")
+	m.GetN("key", 1)
+	if gets, _, _ := m.c.OpStats(); gets != 2 {
+		t.Fatalf("multi-probe gets = %d, want 2", gets)
+	}
+	s := New(WithShards(2), WithInitialNodes("192.168.0.1", "192.168.0.2", "192.168.0.3")).(*sharded)
+	s.Get("key")
+	s.GetN("key", 3)
+	total := uint64(0)
+	for _, shard := range s.shards {
+		gets, _, _ := shard.OpStats()
+		total += gets
+	}
+	if total != 2 {
+		t.Fatalf("sharded gets = %d, want 2", total)
+	}
+}
+
+func TestArcHistogram(t *testing.T) {
+	const buckets, samples = 16, 100000
+	histogram := New(WithHash(md5Hash)).(*consistent).ArcHistogram(buckets, samples)
+	if len(histogram) != buckets {
+		t.Fatalf("len(ArcHistogram) = %d, want %d", len(histogram), buckets)
+	}
+	total := 0
+	for i, count := range histogram {
+		total += count
+		// 均匀的哈希函数每一段的数量都接近平均值
+		if count < samples/buckets*9/10 || count > samples/buckets*11/10 {
+			t.Errorf("arc %d has %d keys, want about %d", i, count, samples/buckets)
+		}
+	}
+	if total != samples {
+		t.Fatalf("ArcHistogram counted %d keys, want %d", total, samples)
+	}
+	t.Logf("md5: %v", histogram)
+	t.Logf("fnv: %v", New(WithHash(fnv32)).(*consistent).ArcHistogram(buckets, samples))
+
+	if h := New(WithHash64(xxhash)).(*consistent).ArcHistogram(3, 10); len(h) != 3 {
+		t.Fatalf("64-bit ArcHistogram returned %d buckets, want 3", len(h))
+	}
+	// 只有一段时 64 位圆环的宽度不能溢出
+	for name, c := range map[string]*consistent{
+		"32-bit": New(WithHash(md5Hash)).(*consistent),
+		"64-bit": New(WithXXHash()).(*consistent),
+	} {
+		if h := c.ArcHistogram(1, 100); len(h) != 1 || h[0] != 100 {
+			t.Fatalf("%s ArcHistogram(1, 100) = %v, want [100]", name, h)
+		}
+	}
+	if h := New().(*consistent).ArcHistogram(0, 10); h != nil {
+		t.Fatalf("ArcHistogram(0) = %v, want nil", h)
+	}
+}
+
+func TestStatsJSON(t *testing.T) {
+	c := New(WithReplicas(200), WithHash(md5Hash)).(*consistent)
+	for i := 0; i < 5; i++ {
+		c.Add(fmt.Sprintf("192.168.0.%d", i))
+	}
+	data, err := c.StatsJSON()
+	if err != nil {
+		t.Fatalf("StatsJSON: %v", err)
+	}
+	t.Log(string(data))
+	var stats struct {
+		Nodes []struct {
+			Node         string  `json:"node"`
+			LoadFactor   float64 `json:"load_factor"`
+			VirtualNodes int     `json:"virtual_nodes"`
+		} `json:"nodes"`
+		StdDev float64 `json:"std_dev"`
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(stats.Nodes) != 5 {
+		t.Fatalf("StatsJSON has %d nodes, want 5", len(stats.Nodes))
+	}
+	for i, node := range stats.Nodes {
+		if node.Node != fmt.Sprintf("192.168.0.%d", i) || node.VirtualNodes != len(c.nodes[node.Node].positions) {
+			t.Fatalf("node %d = %+v", i, node)
+		}
+		if node.LoadFactor < 0.8 || node.LoadFactor > 1.2 || node.LoadFactor != c.LoadFactor(node.Node) {
+			t.Fatalf("load factor of %s = %v, want near 1.0", node.Node, node.LoadFactor)
+		}
+	}
+	if want := c.Stats().StdDev; math.Abs(stats.StdDev-want) > 1e-9 {
+		t.Fatalf("std_dev = %v, want %v", stats.StdDev, want)
+	}
+
+	if data, err := New().(*consistent).StatsJSON(); err != nil || string(data) != `{"nodes":[],"std_dev":0}` {
+		t.Fatalf("StatsJSON on empty ring = %s, %v", data, err)
+	}
+}
+
+func TestWeightedDistributionError(t *testing.T) {
+	if errs := New().(*consistent).WeightedDistributionError(100); len(errs) != 0 {
+		t.Fatalf("WeightedDistributionError on empty ring = %v", errs)
+	}
+	c := New(WithReplicas(1000), WithHash(md5Hash)).(*consistent)
+	c.Add("192.168.0.1")
+	c.AddWeight("192.168.0.2", 2)
+	c.AddWeight("192.168.0.3", 3)
+	c.AddWeightFloat("192.168.0.4", 1.5)
+	c.AddWithReplicas("192.168.0.5", 500)
+	errs := c.WeightedDistributionError(200000)
+	t.Log(errs)
+	if len(errs) != 5 {
+		t.Fatalf("WeightedDistributionError has %d nodes, want 5", len(errs))
+	}
+	for node, e := range errs {
+		if math.Abs(e) > 0.1 {
+			t.Fatalf("relative error of %s = %.4f, want within 0.1", node, e)
+		}
+	}
+}
+
+func TestCollisionReport(t *testing.T) {
+	// a#0 和 b#0 的位置相同，b#1 和 b#2 的位置相同，其他的 key 的位置为 key 本身的数值
+	positions := map[string]uint32{"a#0": 100, "b#0": 100, "a#1": 200, "b#1": 300, "b#2": 300, "a#2": 400}
+	c := New(WithHash(func(name string) uint32 {
+		if pos, ok := positions[name]; ok {
+			return pos
+		}
+		return fnv32(name)
+	}), WithReplicas(3)).(*consistent)
+	c.AddBatch("a", "b")
+	want := []Collision{{Pos: 100, Nodes: []string{"a", "b"}}, {Pos: 300, Nodes: []string{"b", "b"}}}
+	if report := c.CollisionReport(); !reflect.DeepEqual(report, want) {
+		t.Fatalf("CollisionReport() = %v, want %v", report, want)
+	}
+	// 冲突的副本重新探测之后圆环上的位置没有重复，但是报告不受影响
+	if c.VirtualNodes() != 6 {
+		t.Fatalf("VirtualNodes() = %d, want 6", c.VirtualNodes())
+	}
+	c.Delete("b")
+	if report := c.CollisionReport(); report != nil {
+		t.Fatalf("CollisionReport() after Delete = %v, want nil", report)
+	}
+}
@@ -0,0 +1,78 @@
+package consistent
+
+import "sync"
+
+// Ring 将基于字符串的 ConsistentHasher 包装成可以直接保存任意节点类型的圆环
+// 通过 id 获取节点的字符串标识，圆环中保存标识，Ring 负责将标识映射回原始的节点
+type Ring[T comparable] struct {
+	hasher ConsistentHasher
+	id     func(T) string
+	// 标识对应的节点
+	nodes map[string]T
+	sync.RWMutex
+}
+
+// NewRing 使用 hasher 创建新的 Ring，hasher 应该是一个还没有添加任何节点的实例，
+// 不同的节点通过 id 得到的标识不能相同
+func NewRing[T comparable](hasher ConsistentHasher, id func(T) string) *Ring[T] {
+	return &Ring[T]{
+		hasher: hasher,
+		id:     id,
+		nodes:  make(map[string]T),
+	}
+}
+
+// Add 添加一个节点，如果节点已经存在，不做任何处理并返回 false
+func (r *Ring[T]) Add(node T) bool {
+	r.Lock()
+	defer r.Unlock()
+	slot := r.id(node)
+	if !r.hasher.Add(slot) {
+		return false
+	}
+	r.nodes[slot] = node
+	return true
+}
+
+// Delete 删除一个节点
+func (r *Ring[T]) Delete(node T) {
+	r.Lock()
+	defer r.Unlock()
+	slot := r.id(node)
+	r.hasher.Delete(slot)
+	delete(r.nodes, slot)
+}
+
+// Get 获取 key 所属的节点，如果没有任何节点，返回 T 的零值
+func (r *Ring[T]) Get(key string) T {
+	r.RLock()
+	defer r.RUnlock()
+	return r.nodes[r.hasher.Get(key)]
+}
+
+// GetN 获取 key 对应的 n 个不同的节点
+func (r *Ring[T]) GetN(key string, n int) []T {
+	r.RLock()
+	defer r.RUnlock()
+	slots := r.hasher.GetN(key, n)
+	res := make([]T, len(slots))
+	for i, slot := range slots {
+		res[i] = r.nodes[slot]
+	}
+	return res
+}
+
+// Contains 判断节点是否存在
+func (r *Ring[T]) Contains(node T) bool {
+	return r.hasher.Contains(r.id(node))
+}
+
+// Len 获取节点的数量
+func (r *Ring[T]) Len() int {
+	return r.hasher.Len()
+}
+
+// Close 释放底层实现的资源
+func (r *Ring[T]) Close() error {
+	return r.hasher.Close()
+}
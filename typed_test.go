@@ -0,0 +1,51 @@
+package consistent
+
+import (
+	"fmt"
+	"testing"
+)
+
+type server struct {
+	host string
+	port int
+}
+
+func TestRing(t *testing.T) {
+	r := NewRing(New(), func(s *server) string {
+		return fmt.Sprintf("%s:%d", s.host, s.port)
+	})
+	if s := r.Get("key"); s != nil {
+		t.Fatalf("Get on empty ring = %v, want nil", s)
+	}
+
+	servers := []*server{{"192.168.0.1", 6379}, {"192.168.0.1", 6380}, {"192.168.0.2", 6379}}
+	for _, s := range servers {
+		if !r.Add(s) {
+			t.Fatalf("Add(%v) returned false", s)
+		}
+	}
+	if r.Add(&server{"192.168.0.1", 6379}) {
+		t.Fatal("Add with a duplicate identity returned true")
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		s := r.Get(key)
+		if s != servers[0] && s != servers[1] && s != servers[2] {
+			t.Fatalf("Get(%q) = %v, not an added server", key, s)
+		}
+		if nodes := r.GetN(key, 3); len(nodes) != 3 || nodes[0] != s {
+			t.Fatalf("GetN(%q, 3) = %v", key, nodes)
+		}
+	}
+
+	r.Delete(servers[0])
+	if r.Contains(servers[0]) {
+		t.Fatal("Contains returned true after Delete")
+	}
+	for i := 0; i < 100; i++ {
+		if s := r.Get(fmt.Sprintf("key-%d", i)); s == servers[0] {
+			t.Fatal("Get returned a deleted server")
+		}
+	}
+}
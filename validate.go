@@ -0,0 +1,47 @@
+package consistent
+
+import "fmt"
+
+// Validate 检查圆环内部的数据是否一致，发现问题时返回描述该问题的错误
+// 检查 circle 严格递增、circle 与 servers 一一对应、每个副本都属于记录了该位置的节点、
+// 每个节点的副本数量不超过期望的数量 (差值为冲突丢弃的副本)、所有节点的副本数量之和等于 circle 的长度，
+// 以及发布的快照与圆环一致，主要用于测试和模糊测试中的断言
+func (c *consistent) Validate() error {
+	c.rlock()
+	defer c.RUnlock()
+	for i := 1; i < c.circle.Len(); i++ {
+		if c.circle[i-1] >= c.circle[i] {
+			return fmt.Errorf("consistent: circle is not sorted at index %d", i)
+		}
+	}
+	if len(c.servers) != c.circle.Len() {
+		return fmt.Errorf("consistent: %d servers for %d positions", len(c.servers), c.circle.Len())
+	}
+	for _, key := range c.circle {
+		if _, ok := c.servers[key]; !ok {
+			return fmt.Errorf("consistent: position %d has no server", key)
+		}
+	}
+
+	total := 0
+	for node, m := range c.nodes {
+		for _, key := range m.positions {
+			if server, ok := c.servers[key]; !ok || server != node {
+				return fmt.Errorf("consistent: position %d of %s belongs to %q", key, node, server)
+			}
+		}
+		// 冲突的副本会被丢弃，所以副本数量只会少于期望的数量
+		if len(m.positions) > m.count(c.replicas) {
+			return fmt.Errorf("consistent: %s has %d positions, more than %d", node, len(m.positions), m.count(c.replicas))
+		}
+		total += len(m.positions)
+	}
+	// 每个节点的位置都在 servers 中，数量相等说明 servers 中没有不属于任何节点的位置
+	if total != c.circle.Len() {
+		return fmt.Errorf("consistent: nodes own %d positions, circle has %d", total, c.circle.Len())
+	}
+	if snapshot := c.load(); snapshot.circle.Len() != c.circle.Len() || snapshot.nodes != len(c.nodes) {
+		return fmt.Errorf("consistent: snapshot is stale")
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package consistent
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	c := New().(*consistent)
+	c.AddBatch("192.168.0.1", "192.168.0.2")
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	// 破坏圆环之后能够检查出来
+	c.circle[0], c.circle[1] = c.circle[1], c.circle[0]
+	if err := c.Validate(); err == nil {
+		t.Fatal("Validate of unsorted circle returned nil")
+	}
+	c.circle[0], c.circle[1] = c.circle[1], c.circle[0]
+	c.servers[c.circle[0]] = "10.0.0.1"
+	if err := c.Validate(); err == nil {
+		t.Fatal("Validate of mismatched server returned nil")
+	}
+}
+
+// 每个字节表示一次操作，最低位决定添加还是删除，其余位决定节点的编号
+func FuzzValidate(f *testing.F) {
+	f.Add([]byte{0, 2, 4, 1, 3, 0, 0})
+	f.Add([]byte{6, 7, 6, 8, 10, 9, 11, 6})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		// 只有 256 个位置，经常会发生冲突
+		c := New(WithHash(func(name string) uint32 { return hash(name) % 256 })).(*consistent)
+		for _, op := range ops {
+			node := "node-" + strconv.Itoa(int(op>>1)%16)
+			if op&1 == 0 {
+				c.Add(node)
+			} else {
+				c.Delete(node)
+			}
+			if err := c.Validate(); err != nil {
+				t.Fatalf("after %d on %s: %v", op&1, node, err)
+			}
+		}
+	})
+}